@@ -0,0 +1,138 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/elleFlorio/mu-sim/worker"
+)
+
+const adminConfigPath = "/admin/config"
+
+// LiveConfig is the part of the configuration of a service
+// that can be changed while the service is running
+type LiveConfig struct {
+	Workload     string    `json:"workload"`
+	Blocking     float64   `json:"blocking"`
+	Destinations []string  `json:"destinations"`
+	FanOut       string    `json:"fanout"`
+	Quorum       int       `json:"quorum"`
+	Weights      []float64 `json:"weights"`
+	Faults       Faults    `json:"faults"`
+}
+
+// ConfigUpdate changes the live configuration of a service.
+// The missing fields are left unchanged.
+type ConfigUpdate struct {
+	Workload     *string    `json:"workload,omitempty"`
+	Blocking     *float64   `json:"blocking,omitempty"`
+	Destinations *[]string  `json:"destinations,omitempty"`
+	FanOut       *string    `json:"fanout,omitempty"`
+	Quorum       *int       `json:"quorum,omitempty"`
+	Weights      *[]float64 `json:"weights,omitempty"`
+	Faults       *Faults    `json:"faults,omitempty"`
+}
+
+func (s *Service) currentJob() worker.Job {
+	s.mutex_cfg.RLock()
+	defer s.mutex_cfg.RUnlock()
+	return s.job
+}
+
+func (s *Service) currentDestinations() []string {
+	s.mutex_cfg.RLock()
+	defer s.mutex_cfg.RUnlock()
+	return s.cfg.Destinations
+}
+
+// LiveConfig returns the current configuration of the service
+func (s *Service) LiveConfig() LiveConfig {
+	s.mutex_cfg.RLock()
+	defer s.mutex_cfg.RUnlock()
+
+	return LiveConfig{
+		Workload:     s.cfg.Workload,
+		Blocking:     s.cfg.Blocking,
+		Destinations: s.cfg.Destinations,
+		FanOut:       s.cfg.fanOut(),
+		Quorum:       s.cfg.Quorum,
+		Weights:      s.cfg.Weights,
+		Faults:       s.faults.get(),
+	}
+}
+
+// UpdateConfig applies the update to the running service.
+// The update is applied only if the resulting configuration is valid.
+func (s *Service) UpdateConfig(u ConfigUpdate) (LiveConfig, error) {
+	if u.Faults != nil {
+		if err := u.Faults.Validate(); err != nil {
+			return LiveConfig{}, err
+		}
+	}
+
+	s.mutex_cfg.Lock()
+	cfg := s.cfg
+	if u.Workload != nil {
+		cfg.Workload = *u.Workload
+	}
+	if u.Blocking != nil {
+		cfg.Blocking = *u.Blocking
+	}
+	if u.Destinations != nil {
+		cfg.Destinations = *u.Destinations
+		// The weights of the old destinations are meaningless
+		cfg.Weights = nil
+	}
+	if u.FanOut != nil {
+		cfg.FanOut = *u.FanOut
+	}
+	if u.Quorum != nil {
+		cfg.Quorum = *u.Quorum
+	}
+	if u.Weights != nil {
+		cfg.Weights = *u.Weights
+	}
+	job, err := cfg.Validate()
+	if err != nil {
+		s.mutex_cfg.Unlock()
+		return LiveConfig{}, err
+	}
+	s.cfg = cfg
+	s.job = job
+	s.mutex_cfg.Unlock()
+
+	if u.Faults != nil {
+		s.faults.set(*u.Faults)
+	}
+
+	live := s.LiveConfig()
+	s.log.Printf("Configuration updated: %+v\n", live)
+	return live, nil
+}
+
+// serveAdminConfig shows (GET) or updates (PUT or POST)
+// the live configuration of the service
+func (s *Service) serveAdminConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	live := s.LiveConfig()
+	switch r.Method {
+	case "GET":
+	case "PUT", "POST":
+		var u ConfigUpdate
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var err error
+		if live, err = s.UpdateConfig(u); err != nil {
+			http.Error(w, err.Error(), 422)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	json.NewEncoder(w).Encode(live)
+}
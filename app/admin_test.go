@@ -0,0 +1,79 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpdateConfig(t *testing.T) {
+	s := newTestService(t, Config{Name: "a", Workload: "none", Destinations: []string{"b"}})
+	initial := s.LiveConfig()
+
+	bogus := "bogus"
+	blocking := 1.5
+	tests := []ConfigUpdate{
+		{Workload: &bogus},
+		{Blocking: &blocking},
+		{Faults: &Faults{ErrorRate: 2}},
+	}
+
+	// Invalid updates leave the live configuration untouched
+	for _, u := range tests {
+		if _, err := s.UpdateConfig(u); err == nil {
+			t.Errorf("UpdateConfig(%+v) accepted an invalid update", u)
+		}
+		if live := s.LiveConfig(); !reflect.DeepEqual(live, initial) {
+			t.Errorf("UpdateConfig(%+v) changed the configuration to %+v", u, live)
+		}
+	}
+
+	workload := "low"
+	destinations := []string{"c", "d"}
+	live, err := s.UpdateConfig(ConfigUpdate{
+		Workload:     &workload,
+		Destinations: &destinations,
+		Faults:       &Faults{DropRate: 0.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if live.Workload != workload || live.Faults != (Faults{DropRate: 0.5}) {
+		t.Errorf("UpdateConfig() = %+v, want the workload and faults updated", live)
+	}
+	if !reflect.DeepEqual(s.LiveConfig(), live) {
+		t.Errorf("LiveConfig() = %+v, want %+v", s.LiveConfig(), live)
+	}
+	if got := s.currentDestinations(); !reflect.DeepEqual(got, destinations) {
+		t.Errorf("destinations = %v, want %v", got, destinations)
+	}
+}
+
+func TestServeAdminConfig(t *testing.T) {
+	s := newTestService(t, Config{Name: "a", Workload: "none"})
+
+	tests := []struct {
+		method string
+		body   string
+		status int
+	}{
+		{"GET", "", http.StatusOK},
+		{"PUT", `{"blocking": 0.5}`, http.StatusOK},
+		{"PUT", `{"blocking": 2}`, 422},
+		{"POST", `{"workload":`, http.StatusBadRequest},
+		{"DELETE", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		s.serveAdminConfig(w, httptest.NewRequest(test.method, adminConfigPath, strings.NewReader(test.body)))
+		if w.Code != test.status {
+			t.Errorf("%s %s: status %d, want %d", test.method, test.body, w.Code, test.status)
+		}
+	}
+	if blocking := s.LiveConfig().Blocking; blocking != 0.5 {
+		t.Errorf("blocking = %g, want 0.5", blocking)
+	}
+}
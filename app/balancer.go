@@ -0,0 +1,160 @@
+package app
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/balancer"
+	"github.com/elleFlorio/mu-sim/discovery"
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+// loadBalancers keeps the balancer of every destination service. The
+// balancer of a destination is set with "service=strategy", the others
+// use the default strategy (random if not set). With a zone, only the
+// instances in that zone are balanced, unless none is available. It
+// also remembers the instances every request was sent to, to tell the
+// balancers when the requests are over.
+type loadBalancers struct {
+	zone       string
+	strategy   string
+	strategies map[string]string
+	mutex      sync.Mutex
+	balancers  map[string]balancer.Balancer
+	calls      map[string][]call
+}
+
+// call is a request sent to an instance
+type call struct {
+	service  string
+	instance string
+	start    time.Time
+}
+
+// splitBalancer splits "service=strategy" in its parts. The service is
+// empty for the default strategy, that may contain "=" only in its params.
+func splitBalancer(spec string) (string, string) {
+	i := strings.Index(spec, "=")
+	if i < 0 || strings.Contains(spec[:i], ":") {
+		return "", spec
+	}
+	return spec[:i], spec[i+1:]
+}
+
+func validateBalancers(specs []string) error {
+	for _, spec := range specs {
+		_, strategy := splitBalancer(spec)
+		if _, err := balancer.Parse(strategy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// affinityZone is the zone the requests prefer, if any
+func (cfg Config) affinityZone() string {
+	if !cfg.ZoneAffinity {
+		return ""
+	}
+	return cfg.Zone
+}
+
+// sameZone returns the instances in the zone,
+// or all of them if none is in the zone
+func sameZone(instances []discovery.InstanceInfo, zone string) []discovery.InstanceInfo {
+	local := []discovery.InstanceInfo{}
+	for _, instance := range instances {
+		if instance.Zone == zone {
+			local = append(local, instance)
+		}
+	}
+	if len(local) == 0 {
+		return instances
+	}
+	return local
+}
+
+// newLoadBalancers expects specs already validated
+func newLoadBalancers(specs []string, zone string) *loadBalancers {
+	b := &loadBalancers{
+		zone:       zone,
+		strategy:   balancer.Random,
+		strategies: make(map[string]string),
+		balancers:  make(map[string]balancer.Balancer),
+		calls:      make(map[string][]call),
+	}
+	for _, spec := range specs {
+		service, strategy := splitBalancer(spec)
+		if service == "" {
+			b.strategy = strategy
+		} else {
+			b.strategies[service] = strategy
+		}
+	}
+
+	return b
+}
+
+func (b *loadBalancers) balancer(service string) balancer.Balancer {
+	lb, ok := b.balancers[service]
+	if !ok {
+		strategy, ok := b.strategies[service]
+		if !ok {
+			strategy = b.strategy
+		}
+		lb, _ = balancer.Parse(strategy)
+		b.balancers[service] = lb
+	}
+	return lb
+}
+
+// choose picks the instance of the service that receives the request
+func (b *loadBalancers) choose(req network.Request, service string, instances []discovery.InstanceInfo) string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.zone != "" {
+		instances = sameZone(instances, b.zone)
+	}
+
+	instance := b.balancer(service).Choose(instances, req.ID)
+	b.calls[req.Key] = append(b.calls[req.Key], call{service, instance, time.Now()})
+	return instance
+}
+
+// done tells that the instance answered to the request, or that it
+// failed before reaching the instance (so without a latency)
+func (b *loadBalancers) done(key string, instance string, answered bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	calls := b.calls[key]
+	for i, c := range calls {
+		if c.instance != instance {
+			continue
+		}
+		latency := time.Duration(0)
+		if answered {
+			latency = time.Since(c.start)
+		}
+		b.balancer(c.service).Done(instance, latency)
+		b.calls[key] = append(calls[:i:i], calls[i+1:]...)
+		if len(b.calls[key]) == 0 {
+			delete(b.calls, key)
+		}
+		return
+	}
+}
+
+// release ends the calls of a request that is over (complete or expired)
+// without waiting for their responses. Their latency is the time waited.
+func (b *loadBalancers) release(key string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, c := range b.calls[key] {
+		b.balancer(c.service).Done(c.instance, time.Since(c.start))
+	}
+	delete(b.calls, key)
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/elleFlorio/mu-sim/discovery"
+)
+
+func TestSplitBalancer(t *testing.T) {
+	tests := []struct {
+		spec     string
+		service  string
+		strategy string
+	}{
+		{"p2c", "", "p2c"},
+		{"database=consistent-hash", "database", "consistent-hash"},
+		{"database=ewma:decay=5000", "database", "ewma:decay=5000"},
+		{"weighted:http://10.0.0.1:8080=3", "", "weighted:http://10.0.0.1:8080=3"},
+		{"database=weighted:http://10.0.0.1:8080=3", "database", "weighted:http://10.0.0.1:8080=3"},
+	}
+
+	for _, test := range tests {
+		service, strategy := splitBalancer(test.spec)
+		if service != test.service || strategy != test.strategy {
+			t.Errorf("splitBalancer(%q) = %q, %q, want %q, %q", test.spec, service, strategy, test.service, test.strategy)
+		}
+	}
+}
+
+func TestValidateBalancers(t *testing.T) {
+	if err := validateBalancers([]string{"p2c", "database=ewma"}); err != nil {
+		t.Errorf("validateBalancers(): unexpected error %s", err)
+	}
+	if err := validateBalancers([]string{"p2c", "database=fastest"}); err == nil {
+		t.Error("validateBalancers() with an unknown strategy, want an error")
+	}
+}
+
+func TestSameZone(t *testing.T) {
+	instances := []discovery.InstanceInfo{
+		{Address: "a", Zone: "z1"},
+		{Address: "b", Zone: "z2"},
+		{Address: "c", Zone: "z1"},
+	}
+
+	if got := discovery.Addresses(sameZone(instances, "z1")); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("sameZone(z1) = %v, want [a c]", got)
+	}
+	// Without instances in the zone every instance is used
+	if got := sameZone(instances, "z3"); len(got) != 3 {
+		t.Errorf("sameZone(z3) = %v, want every instance", discovery.Addresses(got))
+	}
+}
+
+func TestAffinityZone(t *testing.T) {
+	if zone := (Config{Zone: "z1"}).affinityZone(); zone != "" {
+		t.Errorf("affinityZone() without zone affinity = %q, want none", zone)
+	}
+	if zone := (Config{Zone: "z1", ZoneAffinity: true}).affinityZone(); zone != "z1" {
+		t.Errorf("affinityZone() = %q, want z1", zone)
+	}
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+
+	defaultBreakerCooldown = time.Duration(5) * time.Second
+)
+
+// circuit is the breaker of a single destination
+type circuit struct {
+	state    string
+	failures int
+	openedAt time.Time
+	// When the trial request of the half-open breaker was sent
+	trialAt time.Time
+}
+
+// circuitBreakers keeps a circuit breaker for every destination service.
+// A breaker opens after threshold consecutive failures, failing fast
+// every request to the destination. After the cooldown it lets a trial
+// request through (half-open): if it succeeds the breaker is closed,
+// otherwise it is opened again. A trial without an outcome within the
+// trial timeout (e.g. its response was lost) counts as failed.
+type circuitBreakers struct {
+	threshold    int
+	cooldown     time.Duration
+	trialTimeout time.Duration
+	mutex        sync.Mutex
+	circuits     map[string]*circuit
+	// Service of every instance a request was sent to,
+	// to know which breaker a response belongs to
+	services map[string]string
+}
+
+// transition is a change of state of the breaker of a service
+type transition struct {
+	service string
+	from    string
+	to      string
+}
+
+// newCircuitBreakers returns nil if the threshold is 0, that is
+// when the breakers are disabled and every request is allowed
+func newCircuitBreakers(threshold int, cooldown time.Duration, trialTimeout time.Duration) *circuitBreakers {
+	if threshold == 0 {
+		return nil
+	}
+
+	return &circuitBreakers{
+		threshold:    threshold,
+		cooldown:     cooldown,
+		trialTimeout: trialTimeout,
+		circuits:     make(map[string]*circuit),
+		services:     make(map[string]string),
+	}
+}
+
+func (b *circuitBreakers) circuit(service string) *circuit {
+	c, ok := b.circuits[service]
+	if !ok {
+		c = &circuit{state: BreakerClosed}
+		b.circuits[service] = c
+	}
+	return c
+}
+
+// allow tells if a request can be sent to the service
+func (b *circuitBreakers) allow(service string, now time.Time) (bool, *transition) {
+	if b == nil {
+		return true, nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c := b.circuit(service)
+	switch c.state {
+	case BreakerOpen:
+		if now.Sub(c.openedAt) < b.cooldown {
+			return false, nil
+		}
+		c.state = BreakerHalfOpen
+		c.trialAt = now
+		return true, &transition{service, BreakerOpen, BreakerHalfOpen}
+	case BreakerHalfOpen:
+		// Only the trial request goes through
+		if now.Sub(c.trialAt) < b.trialTimeout {
+			return false, nil
+		}
+		c.state = BreakerOpen
+		c.openedAt = now
+		c.trialAt = time.Time{}
+		return false, &transition{service, BreakerHalfOpen, BreakerOpen}
+	default:
+		return true, nil
+	}
+}
+
+// record updates the breaker of the service with the
+// outcome of a request
+func (b *circuitBreakers) record(service string, success bool, now time.Time) *transition {
+	if b == nil || service == "" {
+		return nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c := b.circuit(service)
+	switch c.state {
+	case BreakerClosed:
+		if success {
+			c.failures = 0
+			return nil
+		}
+		c.failures++
+		if c.failures < b.threshold {
+			return nil
+		}
+	case BreakerHalfOpen:
+		c.trialAt = time.Time{}
+		if success {
+			c.state = BreakerClosed
+			c.failures = 0
+			return &transition{service, BreakerHalfOpen, BreakerClosed}
+		}
+	default:
+		// Late outcomes of requests sent before opening
+		return nil
+	}
+
+	from := c.state
+	c.state = BreakerOpen
+	c.openedAt = now
+	return &transition{service, from, BreakerOpen}
+}
+
+func (b *circuitBreakers) track(instance string, service string) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	b.services[instance] = service
+	b.mutex.Unlock()
+}
+
+func (b *circuitBreakers) serviceOf(instance string) string {
+	if b == nil {
+		return ""
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.services[instance]
+}
+
+func (s *Service) breakerAllows(service string) bool {
+	allowed, t := s.breakers.allow(service, time.Now())
+	s.breakerChanged(t)
+	return allowed
+}
+
+func (s *Service) recordOutcome(service string, success bool) {
+	s.breakerChanged(s.breakers.record(service, success, time.Now()))
+}
+
+func (s *Service) breakerChanged(t *transition) {
+	if t == nil {
+		return
+	}
+
+	s.log.Printf("Circuit breaker of %s: %s -> %s\n", t.service, t.from, t.to)
+	if s.metrics != nil {
+		s.metrics.SendBreakerState(t.service, t.to)
+	}
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+const (
+	testCooldown     = time.Duration(5) * time.Second
+	testTrialTimeout = time.Duration(30) * time.Second
+)
+
+func breakerState(b *circuitBreakers, service string) string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.circuit(service).state
+}
+
+// openBreaker makes the breaker of the service fail threshold times
+func openBreaker(b *circuitBreakers, service string, now time.Time) {
+	for i := 0; i < b.threshold; i++ {
+		b.record(service, false, now)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := newCircuitBreakers(0, testCooldown, testTrialTimeout)
+	if b != nil {
+		t.Fatal("newCircuitBreakers() with threshold 0, want nil")
+	}
+	if allowed, _ := b.allow("db", time.Now()); !allowed {
+		t.Error("allow() of disabled breakers = false")
+	}
+	if tr := b.record("db", false, time.Now()); tr != nil {
+		t.Errorf("record() of disabled breakers = %+v, want no transition", tr)
+	}
+}
+
+func TestBreakerOpens(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreakers(3, testCooldown, testTrialTimeout)
+
+	b.record("db", false, now)
+	b.record("db", false, now)
+	// A success resets the consecutive failures
+	b.record("db", true, now)
+	b.record("db", false, now)
+	b.record("db", false, now)
+	if breakerState(b, "db") != BreakerClosed {
+		t.Fatalf("state = %s after 2 consecutive failures, want closed", breakerState(b, "db"))
+	}
+
+	tr := b.record("db", false, now)
+	if tr == nil || tr.from != BreakerClosed || tr.to != BreakerOpen {
+		t.Fatalf("record() = %+v, want closed -> open", tr)
+	}
+	if allowed, _ := b.allow("db", now.Add(time.Second)); allowed {
+		t.Error("allow() of an open breaker = true")
+	}
+	// The breakers of the other services are independent
+	if allowed, _ := b.allow("cache", now); !allowed {
+		t.Error("allow() of another service = false")
+	}
+}
+
+func TestBreakerTrial(t *testing.T) {
+	tests := []struct {
+		success bool
+		to      string
+	}{
+		{true, BreakerClosed},
+		{false, BreakerOpen},
+	}
+
+	for _, test := range tests {
+		now := time.Now()
+		b := newCircuitBreakers(1, testCooldown, testTrialTimeout)
+		openBreaker(b, "db", now)
+
+		now = now.Add(testCooldown)
+		allowed, tr := b.allow("db", now)
+		if !allowed || tr == nil || tr.to != BreakerHalfOpen {
+			t.Fatalf("allow() after the cooldown = %t, %+v, want the trial", allowed, tr)
+		}
+		if allowed, _ = b.allow("db", now); allowed {
+			t.Fatal("allow() during the trial = true, want only the trial through")
+		}
+
+		tr = b.record("db", test.success, now)
+		if tr == nil || tr.from != BreakerHalfOpen || tr.to != test.to {
+			t.Errorf("record(%t) of the trial = %+v, want half-open -> %s", test.success, tr, test.to)
+		}
+	}
+}
+
+func TestBreakerTrialTimeout(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreakers(1, testCooldown, testTrialTimeout)
+	openBreaker(b, "db", now)
+
+	now = now.Add(testCooldown)
+	b.allow("db", now)
+
+	// The trial never had an outcome: the breaker opens again
+	now = now.Add(testTrialTimeout)
+	allowed, tr := b.allow("db", now)
+	if allowed || tr == nil || tr.from != BreakerHalfOpen || tr.to != BreakerOpen {
+		t.Fatalf("allow() after the trial timeout = %t, %+v, want half-open -> open", allowed, tr)
+	}
+
+	// And lets a new trial through after the cooldown
+	if allowed, _ = b.allow("db", now.Add(testCooldown)); !allowed {
+		t.Error("allow() after the new cooldown = false, want a new trial")
+	}
+}
+
+func TestBreakerLateOutcome(t *testing.T) {
+	now := time.Now()
+	b := newCircuitBreakers(1, testCooldown, testTrialTimeout)
+	openBreaker(b, "db", now)
+
+	// Responses to requests sent before opening do not close the breaker
+	if tr := b.record("db", true, now); tr != nil {
+		t.Errorf("record() of an open breaker = %+v, want no transition", tr)
+	}
+	if breakerState(b, "db") != BreakerOpen {
+		t.Errorf("state = %s, want open", breakerState(b, "db"))
+	}
+}
+
+func TestBreakerTrack(t *testing.T) {
+	b := newCircuitBreakers(1, testCooldown, testTrialTimeout)
+	b.track("http://10.0.0.1:8080", "db")
+	if service := b.serviceOf("http://10.0.0.1:8080"); service != "db" {
+		t.Errorf("serviceOf() = %q, want db", service)
+	}
+	if service := b.serviceOf("http://10.0.0.2:8080"); service != "" {
+		t.Errorf("serviceOf() of an unknown instance = %q, want none", service)
+	}
+}
+
+func TestBreakerCountsCalls(t *testing.T) {
+	g := newGraph()
+	defer g.stop()
+
+	// Every request to the instance that is down fails over to the
+	// other one: the calls succeed and the breaker stays closed
+	g.register(t, "b", network.MemoryAddress("b-down"))
+	g.start(t, Config{Name: "b", Workload: "none"})
+	entry := g.start(t, Config{Name: "a", Workload: "none", Destinations: []string{"b"}, Retries: 2,
+		RetryBackoff: time.Millisecond, BreakerThreshold: 1})
+	a := g.services[1]
+
+	c := g.client(t)
+	for i := 0; i < 10; i++ {
+		if response := c.send(t, entry); response.Body != "done" {
+			t.Fatalf("response = %q, want done", response.Body)
+		}
+	}
+	if state := breakerState(a.breakers, "b"); state != BreakerClosed {
+		t.Errorf("breaker of b = %s, want closed", state)
+	}
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Edge is a call from a service to one of its destinations. A destination
+// is written as "service[:probability][:xcalls]", e.g. "cache:0.8" is
+// called by 80% of the requests and "database:x3" three times per request.
+type Edge struct {
+	Service     string
+	Probability float64
+	Calls       int
+}
+
+// ParseEdge parses the description of a destination
+func ParseEdge(spec string) (Edge, error) {
+	parts := strings.Split(spec, ":")
+	edge := Edge{Service: parts[0], Probability: 1, Calls: 1}
+	if edge.Service == "" {
+		return Edge{}, fmt.Errorf("Invalid destination %q: missing service", spec)
+	}
+	if len(parts) > 3 {
+		return Edge{}, fmt.Errorf("Invalid destination %q", spec)
+	}
+
+	hasProbability, hasCalls := false, false
+	for _, part := range parts[1:] {
+		if strings.HasPrefix(part, "x") {
+			calls, err := strconv.Atoi(part[1:])
+			if err != nil || calls < 1 || hasCalls {
+				return Edge{}, fmt.Errorf("Invalid destination %q: calls must be a positive integer", spec)
+			}
+			edge.Calls = calls
+			hasCalls = true
+			continue
+		}
+
+		probability, err := strconv.ParseFloat(part, 64)
+		if err != nil || probability <= 0 || probability > 1 || hasProbability {
+			return Edge{}, fmt.Errorf("Invalid destination %q: probability must be in (0, 1]", spec)
+		}
+		edge.Probability = probability
+		hasProbability = true
+	}
+
+	return edge, nil
+}
+
+// ParseEdges parses the descriptions of the destinations
+func ParseEdges(specs []string) ([]Edge, error) {
+	edges := make([]Edge, 0, len(specs))
+	for _, spec := range specs {
+		edge, err := ParseEdge(spec)
+		if err != nil {
+			return nil, err
+		}
+		edges = append(edges, edge)
+	}
+
+	return edges, nil
+}
+
+// edges returns the destinations of the service. They
+// must be already validated, so errors are ignored.
+func (cfg Config) edges() []Edge {
+	edges, _ := ParseEdges(cfg.Destinations)
+	return edges
+}
+
+// taken tells if the edge is followed by a request
+func (e Edge) taken() bool {
+	return e.Probability >= 1 || rand.Float64() < e.Probability
+}
+
+// calls repeats the service of the edge once per call
+func (e Edge) calls() []string {
+	calls := make([]string, e.Calls)
+	for i := range calls {
+		calls[i] = e.Service
+	}
+	return calls
+}
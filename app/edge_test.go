@@ -0,0 +1,76 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEdge(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Edge
+	}{
+		{"database", Edge{"database", 1, 1}},
+		{"cache:0.8", Edge{"cache", 0.8, 1}},
+		{"database:x3", Edge{"database", 1, 3}},
+		{"database:0.2:x3", Edge{"database", 0.2, 3}},
+		{"database:x3:0.2", Edge{"database", 0.2, 3}},
+		{"database:1", Edge{"database", 1, 1}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseEdge(test.spec)
+		if err != nil {
+			t.Errorf("ParseEdge(%q): unexpected error %s", test.spec, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseEdge(%q) = %+v, want %+v", test.spec, got, test.want)
+		}
+	}
+}
+
+func TestParseEdgeInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		":0.5",
+		"cache:0",
+		"cache:1.5",
+		"cache:-0.5",
+		"cache:abc",
+		"cache:x0",
+		"cache:x-1",
+		"cache:xa",
+		"cache:0.5:0.5",
+		"cache:x2:x3",
+		"cache:0.5:x2:x3",
+	}
+
+	for _, spec := range tests {
+		if edge, err := ParseEdge(spec); err == nil {
+			t.Errorf("ParseEdge(%q) = %+v, want an error", spec, edge)
+		}
+	}
+}
+
+func TestParseEdges(t *testing.T) {
+	edges, err := ParseEdges([]string{"cache:0.8", "database:x2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Edge{{"cache", 0.8, 1}, {"database", 1, 2}}
+	if !reflect.DeepEqual(edges, want) {
+		t.Errorf("ParseEdges() = %+v, want %+v", edges, want)
+	}
+
+	if _, err = ParseEdges([]string{"cache", "database:x0"}); err == nil {
+		t.Error("ParseEdges() with an invalid destination, want an error")
+	}
+}
+
+func TestEdgeCalls(t *testing.T) {
+	calls := Edge{"database", 1, 3}.calls()
+	if !reflect.DeepEqual(calls, []string{"database", "database", "database"}) {
+		t.Errorf("calls() = %v, want database three times", calls)
+	}
+}
@@ -0,0 +1,242 @@
+package app
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+// How a request is sent to the destinations, and when it is complete
+const (
+	// Send to every destination, complete when all of them responded
+	FanOutAll = "parallel-all"
+	// Send to one destination at a time, in order, complete when the
+	// last one responded (or at the first failure)
+	FanOutSequential = "sequential"
+	// Send to a random destination
+	FanOutRandom = "random-one"
+	// Send to a destination chosen according to the weights
+	FanOutWeighted = "weighted-one"
+	// Send to every destination, complete when a quorum of them succeeded
+	FanOutQuorum = "quorum"
+	// Send to every destination, complete at the first success
+	FanOutFirst = "first-response"
+)
+
+var (
+	ErrInvalidFanOut  = errors.New("Invalid fan-out mode")
+	ErrInvalidWeights = errors.New("Weights must be one per destination, not negative and not all 0")
+)
+
+func validFanOut(mode string) bool {
+	switch mode {
+	case FanOutAll, FanOutSequential, FanOutRandom, FanOutWeighted, FanOutQuorum, FanOutFirst:
+		return true
+	default:
+		return false
+	}
+}
+
+func (cfg Config) fanOut() string {
+	if cfg.FanOut == "" {
+		return FanOutAll
+	}
+	return cfg.FanOut
+}
+
+func (cfg Config) validateFanOut() error {
+	if !validFanOut(cfg.fanOut()) {
+		return ErrInvalidFanOut
+	}
+	if cfg.Quorum < 0 {
+		return errors.New("Quorum cannot be negative")
+	}
+	if cfg.Weights == nil {
+		return nil
+	}
+
+	if len(cfg.Weights) != len(cfg.Destinations) {
+		return ErrInvalidWeights
+	}
+	sum := 0.0
+	for _, w := range cfg.Weights {
+		if w < 0 {
+			return ErrInvalidWeights
+		}
+		sum += w
+	}
+	if sum == 0 && len(cfg.Weights) > 0 {
+		return ErrInvalidWeights
+	}
+
+	return nil
+}
+
+// fanOutPlan is the snapshot of the configuration
+// used to dispatch a request
+type fanOutPlan struct {
+	mode    string
+	quorum  int
+	edges   []Edge
+	weights []float64
+}
+
+func (s *Service) currentFanOut() fanOutPlan {
+	s.mutex_cfg.RLock()
+	defer s.mutex_cfg.RUnlock()
+
+	return fanOutPlan{
+		mode:    s.cfg.fanOut(),
+		quorum:  s.cfg.Quorum,
+		edges:   s.cfg.edges(),
+		weights: s.cfg.Weights,
+	}
+}
+
+// sample chooses the edges followed by a request, according
+// to their probability, together with their weights
+func (p fanOutPlan) sample() ([]Edge, []float64) {
+	var weights []float64
+	edges := make([]Edge, 0, len(p.edges))
+	for i, edge := range p.edges {
+		if !edge.taken() {
+			continue
+		}
+		edges = append(edges, edge)
+		if p.weights != nil {
+			weights = append(weights, p.weights[i])
+		}
+	}
+
+	return edges, weights
+}
+
+// plan chooses the destinations a request is sent to, resolving them to
+// instances, and sets how many responses complete the request. An edge
+// with several calls is sent to its service once per call.
+func (s *Service) plan(req *network.Request, p fanOutPlan) ([]string, []string) {
+	req.FanOut = p.mode
+	edges, weights := p.sample()
+
+	switch p.mode {
+	case FanOutSequential:
+		calls := []string{}
+		for _, edge := range edges {
+			calls = append(calls, edge.calls()...)
+		}
+		for i, service := range calls {
+			destination, err := s.resolveDestination(*req, service)
+			if err != nil {
+				continue
+			}
+			req.Chain = calls[i+1:]
+			req.Counter = 1
+			return []string{service}, []string{destination}
+		}
+		return nil, nil
+
+	case FanOutRandom, FanOutWeighted:
+		// Choose again until an edge can be resolved
+		for len(edges) > 0 {
+			i := rand.Intn(len(edges))
+			if p.mode == FanOutWeighted && weights != nil {
+				i = chooseWeighted(weights)
+			}
+			services, destinations := s.resolveCalls(*req, edges[i:i+1])
+			if len(services) > 0 {
+				req.Counter = len(services)
+				return services, destinations
+			}
+			edges = append(edges[:i], edges[i+1:]...)
+			if weights != nil {
+				weights = append(weights[:i], weights[i+1:]...)
+			}
+		}
+		return nil, nil
+
+	default:
+		calls := 0
+		for _, edge := range edges {
+			calls += edge.Calls
+		}
+		services, destinations := s.resolveCalls(*req, edges)
+		if len(services) < calls {
+			s.log.Println("Cannot dispatch message to all the destinations")
+		}
+		req.Counter = len(services)
+		switch p.mode {
+		case FanOutQuorum:
+			req.Counter = p.quorum
+			if req.Counter == 0 {
+				// Majority of the calls
+				req.Counter = calls/2 + 1
+			}
+			if req.Counter > len(services) {
+				req.Counter = len(services)
+			}
+		case FanOutFirst:
+			req.Counter = 1
+		}
+		req.Spare = len(services) - req.Counter
+		return services, destinations
+	}
+}
+
+// chooseWeighted picks an index with a probability proportional to its weight
+func chooseWeighted(weights []float64) int {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	if total == 0 {
+		return rand.Intn(len(weights))
+	}
+
+	x := rand.Float64() * total
+	for i, w := range weights {
+		x -= w
+		if x < 0 {
+			return i
+		}
+	}
+	return len(weights) - 1
+}
+
+// respond updates the request with the response of a destination,
+// telling if the request is complete and with which status. In
+// sequential mode it also returns the next destination to call.
+func respond(req *network.Request, status string) (bool, string, string) {
+	switch req.FanOut {
+	case FanOutQuorum, FanOutFirst:
+		if status == "done" {
+			req.Counter--
+			return req.Counter <= 0, "done", ""
+		}
+		req.Spare--
+		return req.Spare < 0, status, ""
+
+	case FanOutSequential:
+		if status != "done" || len(req.Chain) == 0 {
+			return true, status, ""
+		}
+		next := req.Chain[0]
+		req.Chain = req.Chain[1:]
+		return false, "", next
+
+	default:
+		// Every destination must respond, and the
+		// request fails if any of them failed
+		req.Counter--
+		if status != "done" && req.Failure == "" {
+			req.Failure = status
+		}
+		if req.Counter > 0 {
+			return false, "", ""
+		}
+		if req.Failure != "" {
+			return true, req.Failure, ""
+		}
+		return true, "done", ""
+	}
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+func TestRespond(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       network.Request
+		responses []string
+		// Response that completes the request (-1 if it never completes)
+		complete int
+		status   string
+	}{
+		{
+			name:      "parallel-all succeeds when all respond",
+			req:       network.Request{FanOut: FanOutAll, Counter: 3},
+			responses: []string{"done", "done", "done"},
+			complete:  2,
+			status:    "done",
+		},
+		{
+			name:      "parallel-all fails with the first failure",
+			req:       network.Request{FanOut: FanOutAll, Counter: 3},
+			responses: []string{"done", "rejected", "error"},
+			complete:  2,
+			status:    "rejected",
+		},
+		{
+			name:      "parallel-all waits for every destination",
+			req:       network.Request{FanOut: FanOutAll, Counter: 3},
+			responses: []string{"error", "done"},
+			complete:  -1,
+		},
+		{
+			name:      "quorum completes with enough successes",
+			req:       network.Request{FanOut: FanOutQuorum, Counter: 2, Spare: 1},
+			responses: []string{"error", "done", "done"},
+			complete:  2,
+			status:    "done",
+		},
+		{
+			name:      "quorum fails when it cannot be reached",
+			req:       network.Request{FanOut: FanOutQuorum, Counter: 2, Spare: 1},
+			responses: []string{"error", "done", "rejected"},
+			complete:  2,
+			status:    "rejected",
+		},
+		{
+			name:      "first-response completes at the first success",
+			req:       network.Request{FanOut: FanOutFirst, Counter: 1, Spare: 2},
+			responses: []string{"error", "done"},
+			complete:  1,
+			status:    "done",
+		},
+		{
+			name:      "first-response fails when every destination failed",
+			req:       network.Request{FanOut: FanOutFirst, Counter: 1, Spare: 2},
+			responses: []string{"error", "error", "timeout"},
+			complete:  2,
+			status:    "timeout",
+		},
+		{
+			name:      "sequential succeeds at the end of the chain",
+			req:       network.Request{FanOut: FanOutSequential, Counter: 1, Chain: []string{"b", "c"}},
+			responses: []string{"done", "done", "done"},
+			complete:  2,
+			status:    "done",
+		},
+		{
+			name:      "sequential stops at the first failure",
+			req:       network.Request{FanOut: FanOutSequential, Counter: 1, Chain: []string{"b", "c"}},
+			responses: []string{"done", "error"},
+			complete:  1,
+			status:    "error",
+		},
+	}
+
+	for _, test := range tests {
+		req := test.req
+		for i, response := range test.responses {
+			complete, status, _ := respond(&req, response)
+			if complete != (i == test.complete) {
+				t.Errorf("%s: response %d complete = %t", test.name, i, complete)
+				break
+			}
+			if complete && status != test.status {
+				t.Errorf("%s: status = %q, want %q", test.name, status, test.status)
+			}
+			if complete {
+				break
+			}
+		}
+	}
+}
+
+func TestRespondSequentialNext(t *testing.T) {
+	req := network.Request{FanOut: FanOutSequential, Counter: 1, Chain: []string{"b", "c"}}
+
+	for _, want := range []string{"b", "c"} {
+		complete, _, next := respond(&req, "done")
+		if complete || next != want {
+			t.Fatalf("respond() = %t, next %q, want the next destination %q", complete, next, want)
+		}
+	}
+}
+
+func TestSequentialChain(t *testing.T) {
+	g := newGraph()
+	defer g.stop()
+
+	entry := g.start(t, Config{Name: "a", Workload: "none", FanOut: FanOutSequential, Destinations: []string{"b", "c"}})
+	g.start(t, Config{Name: "b", Workload: "none"})
+	g.start(t, Config{Name: "c", Workload: "none"})
+
+	if response := g.client(t).send(t, entry); response.Body != "done" {
+		t.Errorf("response = %q, want done", response.Body)
+	}
+}
+
+func TestSequentialChainMissingService(t *testing.T) {
+	g := newGraph()
+	defer g.stop()
+
+	// c has no instances: the chain stops there
+	entry := g.start(t, Config{Name: "a", Workload: "none", FanOut: FanOutSequential, Destinations: []string{"b", "c", "d"}})
+	g.start(t, Config{Name: "b", Workload: "none"})
+	d := &silent{messages: make(chan network.Message, 1)}
+	g.listen(t, "d", d)
+
+	if response := g.client(t).send(t, entry); response.Body != "error" {
+		t.Errorf("response = %q, want error", response.Body)
+	}
+	select {
+	case <-d.messages:
+		t.Error("The request went on after the missing service")
+	default:
+	}
+}
+
+func TestValidateFanOut(t *testing.T) {
+	tests := []struct {
+		cfg   Config
+		valid bool
+	}{
+		{Config{}, true},
+		{Config{FanOut: FanOutQuorum, Quorum: 2}, true},
+		{Config{FanOut: "broadcast"}, false},
+		{Config{FanOut: FanOutQuorum, Quorum: -1}, false},
+		{Config{FanOut: FanOutWeighted, Destinations: []string{"a", "b"}, Weights: []float64{3, 1}}, true},
+		{Config{FanOut: FanOutWeighted, Destinations: []string{"a", "b"}, Weights: []float64{1}}, false},
+		{Config{FanOut: FanOutWeighted, Destinations: []string{"a", "b"}, Weights: []float64{1, -1}}, false},
+		{Config{FanOut: FanOutWeighted, Destinations: []string{"a", "b"}, Weights: []float64{0, 0}}, false},
+	}
+
+	for _, test := range tests {
+		err := test.cfg.validateFanOut()
+		if (err == nil) != test.valid {
+			t.Errorf("validateFanOut(%+v) = %v, want valid %t", test.cfg, err, test.valid)
+		}
+	}
+}
+
+func TestChooseWeighted(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if got := chooseWeighted([]float64{0, 1, 0}); got != 1 {
+			t.Fatalf("chooseWeighted() = %d, want the only weighted index 1", got)
+		}
+	}
+}
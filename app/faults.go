@@ -0,0 +1,134 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const faultsPath = "/faults"
+
+// Faults describes the failures injected in a service
+type Faults struct {
+	// Probability of answering "error" instead of "done"
+	ErrorRate float64 `json:"error_rate"`
+	// Latency added to every response
+	LatencyMs float64 `json:"latency_ms"`
+	// Probability of silently discarding a request
+	DropRate float64 `json:"drop_rate"`
+	// Number of requests after which the service crashes (0 never)
+	CrashAfter int `json:"crash_after"`
+}
+
+var ErrInvalidFaults = errors.New("Fault rates must be between 0 and 1, latency and crash after cannot be negative")
+
+func (f Faults) Validate() error {
+	if f.ErrorRate < 0 || f.ErrorRate > 1 || f.DropRate < 0 || f.DropRate > 1 ||
+		f.LatencyMs < 0 || f.CrashAfter < 0 {
+		return ErrInvalidFaults
+	}
+	return nil
+}
+
+// faultInjector applies the faults of a service. The faults can be
+// changed at runtime, so every access is guarded by the mutex.
+type faultInjector struct {
+	mutex    sync.Mutex
+	faults   Faults
+	received int
+}
+
+func (cfg Config) faults() Faults {
+	return Faults{
+		ErrorRate:  cfg.FaultErrorRate,
+		LatencyMs:  cfg.FaultLatency.Seconds() * 1000,
+		DropRate:   cfg.FaultDropRate,
+		CrashAfter: cfg.FaultCrashAfter,
+	}
+}
+
+func (fi *faultInjector) get() Faults {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	return fi.faults
+}
+
+// set replaces the faults, restarting the count of the
+// requests that make the service crash
+func (fi *faultInjector) set(f Faults) {
+	fi.mutex.Lock()
+	fi.faults = f
+	fi.received = 0
+	fi.mutex.Unlock()
+}
+
+// receive counts a new request, telling if the service
+// should crash instead of accepting it
+func (fi *faultInjector) receive() bool {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.received++
+	return fi.faults.CrashAfter > 0 && fi.received > fi.faults.CrashAfter
+}
+
+func (fi *faultInjector) drop() bool {
+	return happens(fi.get().DropRate)
+}
+
+func (fi *faultInjector) fail() bool {
+	return happens(fi.get().ErrorRate)
+}
+
+func (fi *faultInjector) latency() time.Duration {
+	return time.Duration(fi.get().LatencyMs * float64(time.Millisecond))
+}
+
+func happens(probability float64) bool {
+	return probability > 0 && rand.Float64() < probability
+}
+
+// crash makes the service disappear without answering
+// to its pending requests
+func (s *Service) crash() {
+	s.crashOnce.Do(func() {
+		s.log.Printf("Crashing after %d requests\n", s.faults.get().CrashAfter)
+		close(s.ch_crash)
+		s.halt()
+		s.transport.Close(s.address)
+	})
+}
+
+// Crashed is closed when the service crashes because of an injected fault
+func (s *Service) Crashed() <-chan struct{} {
+	return s.ch_crash
+}
+
+// serveFaults shows (GET) or replaces (PUT or POST)
+// the faults injected in the service
+func (s *Service) serveFaults(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	switch r.Method {
+	case "GET":
+	case "PUT", "POST":
+		var f Faults
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := f.Validate(); err != nil {
+			http.Error(w, err.Error(), 422)
+			return
+		}
+		s.faults.set(f)
+		s.log.Printf("Injected faults: %+v\n", f)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	json.NewEncoder(w).Encode(s.faults.get())
+}
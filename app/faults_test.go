@@ -0,0 +1,111 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+func TestFaultsValidate(t *testing.T) {
+	tests := []struct {
+		faults Faults
+		valid  bool
+	}{
+		{Faults{}, true},
+		{Faults{ErrorRate: 1, DropRate: 0.5, LatencyMs: 10, CrashAfter: 3}, true},
+		{Faults{ErrorRate: 1.5}, false},
+		{Faults{DropRate: -0.1}, false},
+		{Faults{LatencyMs: -1}, false},
+		{Faults{CrashAfter: -1}, false},
+	}
+
+	for _, test := range tests {
+		if err := test.faults.Validate(); (err == nil) != test.valid {
+			t.Errorf("Validate(%+v) = %v, want valid %t", test.faults, err, test.valid)
+		}
+	}
+}
+
+func TestFaultInjectorReceive(t *testing.T) {
+	fi := &faultInjector{faults: Faults{CrashAfter: 2}}
+	for i, want := range []bool{false, false, true, true} {
+		if crash := fi.receive(); crash != want {
+			t.Errorf("receive() #%d = %t, want %t", i+1, crash, want)
+		}
+	}
+
+	// New faults count the requests from the start
+	fi.set(Faults{CrashAfter: 1})
+	if fi.receive() {
+		t.Error("receive() after set = true, want the first request accepted")
+	}
+
+	fi.set(Faults{})
+	for i := 0; i < 10; i++ {
+		if fi.receive() {
+			t.Fatal("receive() without crash after = true")
+		}
+	}
+}
+
+func TestServeFaults(t *testing.T) {
+	s := newTestService(t, Config{Name: "a", Workload: "none", FaultErrorRate: 0.5})
+
+	tests := []struct {
+		method string
+		body   string
+		status int
+		want   Faults
+	}{
+		{"GET", "", http.StatusOK, Faults{ErrorRate: 0.5}},
+		{"PUT", `{"drop_rate": 0.2, "crash_after": 10}`, http.StatusOK, Faults{DropRate: 0.2, CrashAfter: 10}},
+		// Invalid faults leave the current ones
+		{"PUT", `{"drop_rate": 2}`, 422, Faults{DropRate: 0.2, CrashAfter: 10}},
+		{"POST", `{"error_rate":`, http.StatusBadRequest, Faults{DropRate: 0.2, CrashAfter: 10}},
+		{"DELETE", "", http.StatusMethodNotAllowed, Faults{DropRate: 0.2, CrashAfter: 10}},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		s.serveFaults(w, httptest.NewRequest(test.method, faultsPath, strings.NewReader(test.body)))
+		if w.Code != test.status {
+			t.Errorf("%s %s: status %d, want %d", test.method, test.body, w.Code, test.status)
+		}
+		if w.Code == http.StatusOK {
+			var got Faults
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil || got != test.want {
+				t.Errorf("%s %s: body %+v (%v), want %+v", test.method, test.body, got, err, test.want)
+			}
+		}
+		if got := s.faults.get(); got != test.want {
+			t.Errorf("%s %s: faults %+v, want %+v", test.method, test.body, got, test.want)
+		}
+	}
+}
+
+func TestCrashAfter(t *testing.T) {
+	g := newGraph()
+	defer g.stop()
+
+	entry := g.start(t, Config{Name: "a", Workload: "none", FaultCrashAfter: 1})
+	c := g.client(t)
+	if response := c.send(t, entry); response.Body != "done" {
+		t.Fatalf("response = %q, want done", response.Body)
+	}
+
+	// The second request makes the service crash
+	err := g.transport.SendMessage(entry, network.Message{Sender: c.address, Body: "do"}, "")
+	if err != network.ErrUnprocessable {
+		t.Errorf("SendMessage() = %v, want the request refused", err)
+	}
+	select {
+	case <-g.services[0].Crashed():
+	case <-time.After(responseTimeout):
+		t.Fatal("The service did not crash")
+	}
+}
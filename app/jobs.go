@@ -0,0 +1,113 @@
+package app
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/network"
+	"github.com/elleFlorio/mu-sim/worker"
+)
+
+// enqueueReq admits a new request, starting its job
+// if there is a free worker
+func (s *Service) enqueueReq(req network.Request) error {
+	req.QueueLength = s.queue.length()
+	s.addReqToWorks(req)
+
+	start, dropped, err := s.queue.push(req)
+	s.dropReqs(dropped)
+	if err != nil {
+		s.refuseReq(req)
+		return err
+	}
+
+	if start {
+		s.startWorker(req)
+	} else {
+		s.log.Printf("Request %s queued\n", req.ID)
+	}
+
+	return nil
+}
+
+func (s *Service) startWorker(req network.Request) {
+	req.QueueTimeMs = time.Since(req.Start).Seconds() * 1000
+	s.log.Println("Starting new worker on request ", req.ID)
+	go worker.Work(s.currentJob(), req, s.ch_done)
+}
+
+// refuseReq discards a new request because the service is
+// overloaded. The sender learns it from the error of the transport.
+func (s *Service) refuseReq(req network.Request) {
+	s.removeReqFromWorks(req.Key)
+	s.log.Printf("Request %s refused: service overloaded\n", req.ID)
+	s.traceRequest(req, "rejected")
+	if s.metrics != nil {
+		s.metrics.SendRejection()
+	}
+}
+
+// rejectReq answers to a queued request that will not be
+// computed because the service is overloaded
+func (s *Service) rejectReq(req network.Request) {
+	s.removeReqFromWorks(req.Key)
+	s.log.Printf("Request %s rejected: service overloaded\n", req.ID)
+	go s.respondeToRequest(req, "rejected")
+	s.traceRequest(req, "rejected")
+	if s.metrics != nil {
+		s.metrics.SendRejection()
+	}
+}
+
+func (s *Service) dropReqs(dropped []network.Request) {
+	for _, req := range dropped {
+		s.rejectReq(req)
+	}
+}
+
+func (s *Service) jobsManager() {
+	s.log.Println("Started work manager. Waiting for work to do...")
+	for {
+		select {
+		case reqDone := <-s.ch_done:
+			next, ok, dropped := s.queue.done()
+			s.dropReqs(dropped)
+			if ok {
+				s.startWorker(next)
+			}
+			s.log.Printf("Request %s computed", reqDone.ID)
+			s.log.Println("service " + s.name + " " + "execution_time:" + strconv.FormatFloat(reqDone.ExecTimeMs, 'f', 2, 64) + "ms" +
+				" queue_time:" + strconv.FormatFloat(reqDone.QueueTimeMs, 'f', 2, 64) + "ms" +
+				" queue_length:" + strconv.Itoa(reqDone.QueueLength))
+			if reqDone.IOBytes > 0 {
+				s.log.Println("service " + s.name + " " + "io_time:" + strconv.FormatFloat(reqDone.IOTimeMs, 'f', 2, 64) + "ms" +
+					" io_bytes:" + strconv.FormatInt(reqDone.IOBytes, 10))
+			}
+			s.finalizeReq(reqDone)
+			s.removeReqFromWorks(reqDone.Key)
+			if s.metrics != nil {
+				s.metrics.SendExecutionTime(reqDone.ExecTimeMs)
+				s.metrics.SendQueueTime(reqDone.QueueTimeMs)
+				s.metrics.SendQueueLength(reqDone.QueueLength)
+				if reqDone.IOBytes > 0 {
+					s.metrics.SendIOTime(reqDone.IOTimeMs)
+					s.metrics.SendIOBytes(reqDone.IOBytes)
+				}
+			}
+		case <-s.ch_quit:
+			return
+		}
+	}
+}
+
+func (s *Service) addReqToWorks(req network.Request) {
+	s.mutex_w.Lock()
+	s.jobs[req.Key] = req
+	s.mutex_w.Unlock()
+}
+
+func (s *Service) removeReqFromWorks(key string) {
+	s.mutex_w.Lock()
+	delete(s.jobs, key)
+	s.mutex_w.Unlock()
+}
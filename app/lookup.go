@@ -0,0 +1,63 @@
+package app
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/discovery"
+)
+
+const lookupReportInterval = time.Duration(10) * time.Second
+
+// lookupStats sums the time spent looking up the instances of the
+// destinations. It is reported periodically, so that sending the
+// metric does not slow down every request.
+type lookupStats struct {
+	mutex sync.Mutex
+	count int
+	total time.Duration
+}
+
+func (l *lookupStats) add(d time.Duration) {
+	l.mutex.Lock()
+	l.count++
+	l.total += d
+	l.mutex.Unlock()
+}
+
+// reset returns the lookups done since the last reset
+func (l *lookupStats) reset() (int, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	count, total := l.count, l.total
+	l.count, l.total = 0, 0
+	return count, total
+}
+
+// lookup finds the available instances of the service,
+// recording how long the discovery took
+func (s *Service) lookup(service string) ([]discovery.InstanceInfo, error) {
+	start := time.Now()
+	instances, err := s.registry.GetAvailableInstances(service)
+	s.lookups.add(time.Since(start))
+	return instances, err
+}
+
+// reportLookups periodically sends the average time of the lookups
+func (s *Service) reportLookups() {
+	ticker := time.NewTicker(lookupReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			count, total := s.lookups.reset()
+			if count > 0 {
+				s.metrics.SendLookupTime(total.Seconds() * 1000 / float64(count))
+			}
+		case <-s.ch_quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+// Admission policies applied when the service is overloaded
+const (
+	// Reject the new requests when the queue is full
+	PolicyReject = "reject"
+	// Drop the oldest request in the queue to make room for the new one
+	PolicyDropOldest = "drop-oldest"
+	// Serve the newest requests first when the queue is more than half
+	// full (always, if the queue is unlimited), dropping the oldest
+	// ones when it is full
+	PolicyLIFO = "lifo"
+	// Drop the requests that waited in the queue longer than the CoDel
+	// interval, or than the CoDel target if the queue has not been
+	// empty during the last interval
+	PolicyCoDel = "codel"
+)
+
+const (
+	defaultCoDelTarget   = time.Duration(5) * time.Millisecond
+	defaultCoDelInterval = time.Duration(100) * time.Millisecond
+)
+
+var ErrInvalidPolicy = errors.New("Invalid admission policy")
+
+// jobQueue limits the number of requests computed at the same
+// time, keeping the exceeding ones in a queue
+type jobQueue struct {
+	mutex     sync.Mutex
+	workers   int
+	maxLength int
+	policy    string
+	target    time.Duration
+	interval  time.Duration
+	lastEmpty time.Time
+	running   int
+	waiting   []queuedRequest
+}
+
+type queuedRequest struct {
+	req     network.Request
+	timeout time.Duration
+}
+
+func newJobQueue(workers int, maxLength int, policy string, target time.Duration, interval time.Duration) *jobQueue {
+	return &jobQueue{
+		workers:   workers,
+		maxLength: maxLength,
+		policy:    policy,
+		target:    target,
+		interval:  interval,
+		lastEmpty: time.Now(),
+	}
+}
+
+func validPolicy(policy string) bool {
+	switch policy {
+	case PolicyReject, PolicyDropOldest, PolicyLIFO, PolicyCoDel:
+		return true
+	}
+	return false
+}
+
+// push admits a new request, returning true if a worker is free
+// to compute it immediately, and the requests dropped to make room
+// for it. A limit equal to 0 means unlimited.
+func (q *jobQueue) push(req network.Request) (bool, []network.Request, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.workers <= 0 || q.running < q.workers {
+		q.running++
+		return true, nil, nil
+	}
+
+	var dropped []network.Request
+	if q.maxLength > 0 && len(q.waiting) >= q.maxLength {
+		switch q.policy {
+		case PolicyDropOldest, PolicyLIFO:
+			dropped = append(dropped, q.popFront().req)
+		default:
+			return false, nil, network.ErrOverloaded
+		}
+	}
+
+	if len(q.waiting) == 0 {
+		q.lastEmpty = time.Now()
+	}
+	var timeout time.Duration
+	if q.policy == PolicyCoDel {
+		timeout = q.interval
+		if time.Since(q.lastEmpty) > q.interval {
+			timeout = q.target
+		}
+	}
+	q.waiting = append(q.waiting, queuedRequest{req, timeout})
+
+	return false, dropped, nil
+}
+
+// done frees the worker of a completed job and returns the next
+// request to compute, if any, and the requests dropped meanwhile
+func (q *jobQueue) done() (network.Request, bool, []network.Request) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var dropped []network.Request
+	for len(q.waiting) > 0 {
+		var next queuedRequest
+		if q.policy == PolicyLIFO && q.overloaded() {
+			next = q.popBack()
+		} else {
+			next = q.popFront()
+		}
+
+		if next.timeout > 0 && time.Since(next.req.Start) > next.timeout {
+			dropped = append(dropped, next.req)
+			continue
+		}
+
+		if len(q.waiting) == 0 {
+			q.lastEmpty = time.Now()
+		}
+		return next.req, true, dropped
+	}
+
+	q.running--
+	q.lastEmpty = time.Now()
+	return network.Request{}, false, dropped
+}
+
+func (q *jobQueue) overloaded() bool {
+	if q.maxLength <= 0 {
+		return true
+	}
+	return len(q.waiting) > q.maxLength/2
+}
+
+func (q *jobQueue) popFront() queuedRequest {
+	first := q.waiting[0]
+	q.waiting[0] = queuedRequest{}
+	q.waiting = q.waiting[1:]
+	return first
+}
+
+func (q *jobQueue) popBack() queuedRequest {
+	last := q.waiting[len(q.waiting)-1]
+	q.waiting = q.waiting[:len(q.waiting)-1]
+	return last
+}
+
+func (q *jobQueue) length() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.waiting)
+}
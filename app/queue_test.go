@@ -0,0 +1,164 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+func queued(id string) network.Request {
+	return network.Request{ID: id, Start: time.Now()}
+}
+
+// fill starts as many requests as the workers and queues the others
+func fill(t *testing.T, q *jobQueue, ids ...string) []string {
+	dropped := []string{}
+	for _, id := range ids {
+		_, d, err := q.push(queued(id))
+		if err != nil {
+			t.Fatalf("push(%s): unexpected error %s", id, err)
+		}
+		for _, req := range d {
+			dropped = append(dropped, req.ID)
+		}
+	}
+	return dropped
+}
+
+// drain completes the running jobs one at a time, returning
+// the order the queued requests are computed in
+func drain(q *jobQueue) []string {
+	order := []string{}
+	for {
+		next, ok, _ := q.done()
+		if !ok {
+			return order
+		}
+		order = append(order, next.ID)
+	}
+}
+
+func sameIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestJobQueueUnlimited(t *testing.T) {
+	q := newJobQueue(0, 0, PolicyReject, 0, 0)
+	for _, id := range []string{"a", "b", "c"} {
+		run, dropped, err := q.push(queued(id))
+		if !run || len(dropped) > 0 || err != nil {
+			t.Fatalf("push(%s) = %t, %v, %v, want the request to run", id, run, dropped, err)
+		}
+	}
+}
+
+func TestJobQueueFIFO(t *testing.T) {
+	q := newJobQueue(1, 0, PolicyReject, 0, 0)
+	fill(t, q, "a", "b", "c", "d")
+	if q.length() != 3 {
+		t.Fatalf("length() = %d, want 3", q.length())
+	}
+	if order := drain(q); !sameIDs(order, []string{"b", "c", "d"}) {
+		t.Errorf("order = %v, want [b c d]", order)
+	}
+	if q.running != 0 {
+		t.Errorf("running = %d after draining the queue, want 0", q.running)
+	}
+}
+
+func TestJobQueuePolicies(t *testing.T) {
+	tests := []struct {
+		policy   string
+		rejected bool
+		dropped  []string
+		order    []string
+	}{
+		// The queue holds 2 requests: "d" does not fit
+		{PolicyReject, true, []string{}, []string{"b", "c"}},
+		{PolicyDropOldest, false, []string{"b"}, []string{"c", "d"}},
+		{PolicyLIFO, false, []string{"b"}, []string{"d", "c"}},
+	}
+
+	for _, test := range tests {
+		q := newJobQueue(1, 2, test.policy, 0, 0)
+		fill(t, q, "a", "b", "c")
+
+		run, d, err := q.push(queued("d"))
+		if run {
+			t.Errorf("%s: push(d) runs the request with no free worker", test.policy)
+		}
+		if (err == network.ErrOverloaded) != test.rejected {
+			t.Errorf("%s: push(d) error = %v, want rejected %t", test.policy, err, test.rejected)
+		}
+		dropped := []string{}
+		for _, req := range d {
+			dropped = append(dropped, req.ID)
+		}
+		if !sameIDs(dropped, test.dropped) {
+			t.Errorf("%s: dropped = %v, want %v", test.policy, dropped, test.dropped)
+		}
+		if order := drain(q); !sameIDs(order, test.order) {
+			t.Errorf("%s: order = %v, want %v", test.policy, order, test.order)
+		}
+	}
+}
+
+func TestJobQueueLIFOUnderHalf(t *testing.T) {
+	// Up to half full the LIFO queue is served in order
+	q := newJobQueue(1, 4, PolicyLIFO, 0, 0)
+	fill(t, q, "a", "b", "c")
+	if order := drain(q); !sameIDs(order, []string{"b", "c"}) {
+		t.Errorf("order = %v, want [b c]", order)
+	}
+}
+
+func TestJobQueueCoDel(t *testing.T) {
+	target := time.Duration(2) * time.Millisecond
+	interval := time.Duration(20) * time.Millisecond
+	q := newJobQueue(1, 0, PolicyCoDel, target, interval)
+	fill(t, q, "a", "b")
+
+	// "b" is served within the interval
+	if next, ok, dropped := q.done(); !ok || next.ID != "b" || len(dropped) > 0 {
+		t.Fatalf("done() = %s, %t, dropped %v, want b", next.ID, ok, dropped)
+	}
+
+	fill(t, q, "c")
+	time.Sleep(2 * interval)
+	// The queue has not been empty for longer than the
+	// interval, so "d" may wait only for the target
+	fill(t, q, "d")
+	time.Sleep(4 * target)
+
+	next, ok, dropped := q.done()
+	if ok {
+		t.Errorf("done() = %s, want no request", next.ID)
+	}
+	ids := []string{}
+	for _, req := range dropped {
+		ids = append(ids, req.ID)
+	}
+	if !sameIDs(ids, []string{"c", "d"}) {
+		t.Errorf("done() dropped %v, want [c d]", ids)
+	}
+}
+
+func TestValidPolicy(t *testing.T) {
+	for _, policy := range []string{PolicyReject, PolicyDropOldest, PolicyLIFO, PolicyCoDel} {
+		if !validPolicy(policy) {
+			t.Errorf("validPolicy(%q) = false", policy)
+		}
+	}
+	if validPolicy("random") {
+		t.Error("validPolicy(\"random\") = true")
+	}
+}
@@ -0,0 +1,344 @@
+package app
+
+import (
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/discovery"
+	"github.com/elleFlorio/mu-sim/network"
+	"github.com/elleFlorio/mu-sim/tracing"
+)
+
+// HandleMessage receives a new request for the service
+func (s *Service) HandleMessage(message network.Message, toService string) error {
+	if s.faults.receive() {
+		go s.crash()
+		return network.ErrUnprocessable
+	}
+
+	// Create the request
+	req := s.createReq(message, toService)
+	if s.faults.drop() {
+		s.log.Printf("Request %s dropped (injected fault)\n", req.ID)
+		return nil
+	}
+	if expired(req.Deadline, req.Start) {
+		go s.timeoutReq(req)
+		return nil
+	}
+
+	// Start work
+	select {
+	case <-s.ch_quit:
+		return network.ErrUnprocessable
+	default:
+		return s.enqueueReq(req)
+	}
+}
+
+func (s *Service) createReq(message network.Message, toService string) network.Request {
+	var requestID string
+	var start = time.Now()
+
+	key := strconv.Itoa(s.readAndIncrementCounter())
+	requestID = message.Args
+	if requestID == "" {
+		s.log.Println("New request, generating ID")
+		requestID = s.generateRequestID(key)
+	}
+	s.log.Printf("Received request %s from %s\n", requestID, message.Sender)
+
+	req := network.Request{
+		ID:         requestID,
+		Key:        key,
+		From:       message.Sender,
+		Ref:        message.Ref,
+		To:         toService,
+		Start:      start,
+		ExecTimeMs: 0,
+		TraceID:    message.Trace,
+		ParentSpan: message.Span,
+	}
+	if message.Deadline > 0 {
+		req.Deadline = time.Unix(0, message.Deadline*int64(time.Millisecond))
+	} else if s.deadline > 0 {
+		req.Deadline = start.Add(s.deadline)
+	}
+	if s.tracer != nil {
+		if req.TraceID == "" {
+			req.TraceID = tracing.NewTraceID()
+		}
+		req.SpanID = tracing.NewSpanID()
+	}
+
+	return req
+}
+
+// generateRequestID creates an ID that is unique among
+// all the services, so requests coming from different
+// entry points never get mixed up
+func (s *Service) generateRequestID(key string) string {
+	uuid, err := discovery.GenerateUUID()
+	if err != nil {
+		s.log.Println("Cannot generate UUID: ", err)
+		return s.address + "/" + key
+	}
+
+	return uuid
+}
+
+func (s *Service) finalizeReq(reqDone network.Request) {
+	reqDone.Dispatched = time.Now()
+	s.traceJob(reqDone)
+	if expired(reqDone.Deadline, reqDone.Dispatched) {
+		s.timeoutReq(reqDone)
+		return
+	}
+	reqDone.Expires = s.expiration(reqDone)
+	if s.faults.fail() {
+		s.log.Printf("Request %s failed (injected fault)\n", reqDone.ID)
+		s.respondeToRequest(reqDone, "error")
+		s.traceRequest(reqDone, "error")
+		return
+	}
+
+	if reqDone.To != "" {
+		destination, err := s.resolveDestination(reqDone, reqDone.To)
+		if err != nil {
+			s.respondeToRequest(reqDone, "error")
+			s.traceRequest(reqDone, "error")
+			return
+		}
+		reqDone.FanOut = FanOutAll
+		reqDone.Counter = 1
+		s.dispatchReq(reqDone, []string{reqDone.To}, []string{destination})
+		return
+	}
+
+	services, destinations := s.plan(&reqDone, s.currentFanOut())
+	if len(destinations) == 0 {
+		s.respondeToRequest(reqDone, "done")
+		s.traceRequest(reqDone, "done")
+		return
+	}
+	s.dispatchReq(reqDone, services, destinations)
+}
+
+// dispatchReq sends a request to the destinations, after adding it to
+// the history: this way even the fastest response will find it there
+func (s *Service) dispatchReq(req network.Request, services []string, destinations []string) {
+	req.Waiting = services
+	s.addRequestToHistory(req)
+	for i, destination := range destinations {
+		s.sendReqToDest(req, services[i], destination)
+	}
+}
+
+// resolveDestination chooses the instance of the service
+// that receives the request
+func (s *Service) resolveDestination(req network.Request, service string) (string, error) {
+	instances, err := s.lookup(service)
+	if err != nil {
+		s.log.Println("Cannot dispatch message to service ", service)
+		return "", err
+	}
+	return s.balancers.choose(req, service, instances), nil
+}
+
+// resolveCalls chooses an instance for every call of the edges whose
+// service has at least one available instance, returning the
+// service and the instance of every resolved call
+func (s *Service) resolveCalls(req network.Request, edges []Edge) ([]string, []string) {
+	services := []string{}
+	resolved := []string{}
+
+	for _, edge := range edges {
+		instances, err := s.lookup(edge.Service)
+		if err != nil {
+			s.log.Println("Cannot dispatch message to service ", edge.Service)
+			continue
+		}
+		for i := 0; i < edge.Calls; i++ {
+			services = append(services, edge.Service)
+			resolved = append(resolved, s.balancers.choose(req, edge.Service, instances))
+		}
+	}
+
+	return services, resolved
+}
+
+func (s *Service) sendReqToDest(req network.Request, service string, dest string) {
+	if !s.breakerAllows(service) {
+		s.log.Printf("Circuit breaker of %s open: request %s failed\n", service, req.ID)
+		s.balancers.done(req.Key, dest, false)
+		s.completeDestination(req.Key, req.ID, service, "error")
+		return
+	}
+
+	message := network.Message{
+		Sender:   s.address,
+		Body:     "do",
+		Args:     req.ID,
+		Ref:      req.Key,
+		Deadline: req.Expires.UnixNano() / int64(time.Millisecond),
+		Trace:    req.TraceID,
+		Span:     req.SpanID,
+	}
+	if message.Span == "" {
+		// This service is not traced: its destinations
+		// are children of the span of its sender
+		message.Span = req.ParentSpan
+	}
+	go s.deliver(req, service, dest, message)
+	s.log.Printf("Request %s sent to %s\n", req.ID, dest)
+}
+
+func (s *Service) readAndIncrementCounter() int {
+	s.mutex_c.Lock()
+	c := s.counter
+	s.counter++
+	s.mutex_c.Unlock()
+	runtime.Gosched()
+
+	return c
+}
+
+func (s *Service) addRequestToHistory(req network.Request) {
+	s.mutex_r.Lock()
+	s.requests[req.Key] = req
+	s.mutex_r.Unlock()
+	runtime.Gosched()
+	s.log.Printf("Added request %s to history\n", req.ID)
+}
+
+func (s *Service) respondeToRequest(req network.Request, status string) {
+	message := network.Message{
+		Sender: s.address,
+		Body:   status,
+		Args:   req.ID,
+		Ref:    req.Ref,
+	}
+	if latency := s.faults.latency(); latency > 0 {
+		time.AfterFunc(latency, func() { s.sendResponse(req, message) })
+		return
+	}
+	s.sendResponse(req, message)
+}
+
+func (s *Service) sendResponse(req network.Request, message network.Message) {
+	select {
+	case <-s.ch_crash:
+		// A crashed service is silent
+		return
+	default:
+	}
+
+	if err := s.transport.SendResponse(req.From, message); err != nil {
+		s.log.Printf("Cannot send response to request %s to %s: %s\n", req.ID, req.From, err.Error())
+		return
+	}
+	s.log.Printf("Response to request %s sent to %s\n", req.ID, req.From)
+}
+
+// HandleResponse receives the response of a destination to a request
+func (s *Service) HandleResponse(message network.Message) error {
+	s.log.Println("Received response from ", message.Sender)
+
+	s.balancers.done(message.Ref, message.Sender, true)
+	service := s.breakers.serviceOf(message.Sender)
+	// Late responses count too, or the trial of a
+	// half-open breaker might never have an outcome
+	s.recordOutcome(service, message.Body == "done")
+
+	return s.completeDestination(message.Ref, message.Args, service, message.Body)
+}
+
+// completeDestination records the outcome of a request sent
+// to a destination, answering to the request once every
+// destination is complete
+func (s *Service) completeDestination(key string, reqId string, service string, status string) error {
+	var respTimeMs float64
+
+	s.mutex_r.Lock()
+	req, ok := s.requests[key]
+	s.mutex_r.Unlock()
+	if ok && req.ID == reqId {
+		respTimeMs = time.Since(req.Start).Seconds() * 1000
+		req, complete, result, next := s.updateRequestInHistory(key, service, status)
+		if complete {
+			s.balancers.release(key)
+			s.respondeToRequest(req, result)
+			s.traceDownstream(req, result)
+			s.traceRequest(req, result)
+		} else if next != "" {
+			s.sendReqToNext(req, next)
+		}
+	} else {
+		s.log.Println(ErrUnknownRequest)
+		return ErrUnknownRequest
+	}
+	if status == "done" {
+		s.log.Println("service " + s.name + " " + "response_time" + ":" + strconv.FormatFloat(respTimeMs, 'f', 2, 64) + "ms")
+		if s.metrics != nil {
+			s.metrics.SendResponseTime(respTimeMs)
+		}
+	} else {
+		s.log.Println("Error: request lost.")
+	}
+
+	return nil
+}
+
+// updateRequestInHistory records the response of a destination. It
+// returns the updated request, whether it is complete (and so removed
+// from the history) with its final status, and the next destination
+// to call in sequential mode.
+func (s *Service) updateRequestInHistory(key string, service string, status string) (network.Request, bool, string, string) {
+	s.mutex_r.Lock()
+	req, ok := s.requests[key]
+	if !ok {
+		// The request expired in the meanwhile
+		s.mutex_r.Unlock()
+		return req, false, "", ""
+	}
+	req.Waiting = removeService(req.Waiting, service)
+	complete, result, next := respond(&req, status)
+	if complete {
+		delete(s.requests, key)
+		s.log.Printf("Removed request %s from history\n", req.ID)
+	} else {
+		if next != "" {
+			req.Waiting = append(req.Waiting, next)
+		}
+		s.requests[key] = req
+		s.log.Printf("Updated counter  of request %s: %d\n", req.ID, req.Counter)
+	}
+	s.mutex_r.Unlock()
+	runtime.Gosched()
+	return req, complete, result, next
+}
+
+// sendReqToNext sends a request to the next service of a sequential chain
+func (s *Service) sendReqToNext(req network.Request, service string) {
+	destination, err := s.resolveDestination(req, service)
+	if err != nil {
+		// The chain cannot go on without the next service
+		s.log.Printf("Cannot resolve %s: request %s failed\n", service, req.ID)
+		s.completeDestination(req.Key, req.ID, service, "error")
+		return
+	}
+	s.sendReqToDest(req, service, destination)
+}
+
+// removeService removes the first occurrence of service
+func removeService(services []string, service string) []string {
+	for i, s := range services {
+		if s == service {
+			waiting := make([]string, 0, len(services)-1)
+			waiting = append(waiting, services[:i]...)
+			return append(waiting, services[i+1:]...)
+		}
+	}
+	return services
+}
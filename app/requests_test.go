@@ -0,0 +1,72 @@
+package app
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+func TestCreateReqUnique(t *testing.T) {
+	services := []*Service{
+		newTestService(t, Config{Name: "a", Workload: "none"}),
+		newTestService(t, Config{Name: "b", Workload: "none"}),
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	ids := map[string]bool{}
+	keys := map[*Service]map[string]bool{}
+	for _, s := range services {
+		keys[s] = map[string]bool{}
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func(s *Service) {
+				defer wg.Done()
+				req := s.createReq(network.Message{Body: "do"}, "")
+				mutex.Lock()
+				defer mutex.Unlock()
+				if ids[req.ID] {
+					t.Errorf("request ID %s generated twice", req.ID)
+				}
+				if keys[s][req.Key] {
+					t.Errorf("key %s of %s used twice", req.Key, s.name)
+				}
+				ids[req.ID] = true
+				keys[s][req.Key] = true
+			}(s)
+		}
+	}
+	wg.Wait()
+}
+
+func TestCreateReqArrivals(t *testing.T) {
+	s := newTestService(t, Config{Name: "a", Workload: "none"})
+
+	// The same request arriving twice keeps its ID, with a key per arrival
+	first := s.createReq(network.Message{Body: "do", Args: "r1"}, "")
+	second := s.createReq(network.Message{Body: "do", Args: "r1"}, "")
+	if first.ID != "r1" || second.ID != "r1" {
+		t.Errorf("IDs = %s, %s, want the ID of the message", first.ID, second.ID)
+	}
+	if first.Key == second.Key {
+		t.Errorf("both arrivals have key %s", first.Key)
+	}
+}
+
+func TestServiceGraphDiamond(t *testing.T) {
+	g := newGraph()
+	defer g.stop()
+
+	// d receives the request of a twice, from b and from c
+	entry := g.start(t, Config{Name: "a", Workload: "none", Destinations: []string{"b", "c"}})
+	g.start(t, Config{Name: "b", Workload: "none", Destinations: []string{"d"}})
+	g.start(t, Config{Name: "c", Workload: "none", Destinations: []string{"d"}})
+	g.start(t, Config{Name: "d", Workload: "none", Destinations: []string{"e"}})
+	g.start(t, Config{Name: "e", Workload: "none"})
+
+	response := g.client(t).send(t, entry)
+	if response.Body != "done" {
+		t.Errorf("response = %q, want done", response.Body)
+	}
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/discovery"
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+const (
+	defaultRetryBackoff    = time.Duration(50) * time.Millisecond
+	defaultRetryMaxBackoff = time.Duration(1) * time.Second
+)
+
+// retryPolicy controls how many times a failed dispatch is
+// retried and how long to wait before every retry
+type retryPolicy struct {
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// delay computes the wait before the given retry (starting from 0):
+// an exponential backoff with full jitter, so that the retries of
+// different requests are spread instead of hitting the destination together
+func (p retryPolicy) delay(retry int) time.Duration {
+	d := p.maxBackoff
+	if retry < 32 && p.backoff<<uint(retry) < p.maxBackoff {
+		d = p.backoff << uint(retry)
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+// deliver sends a request to an instance of the destination service.
+// If the dispatch fails it is retried on a different instance, when
+// available, and once the retries are over the destination is
+// considered complete with an error.
+func (s *Service) deliver(req network.Request, service string, dest string, message network.Message) {
+	failed := []string{}
+
+	for retry := 0; ; retry++ {
+		s.breakers.track(dest, service)
+		err := s.transport.SendMessage(dest, message, "")
+		if err == nil {
+			return
+		}
+		s.log.Printf("Cannot send request %s to %s: %s\n", req.ID, dest, err.Error())
+		s.balancers.done(req.Key, dest, false)
+
+		if retry >= s.retry.retries {
+			// The breaker counts the call to the destination once,
+			// not every attempt: a retry may still succeed
+			s.recordOutcome(service, false)
+			s.completeDestination(req.Key, req.ID, service, failureStatus(err))
+			return
+		}
+
+		failed = append(failed, dest)
+		time.Sleep(s.retry.delay(retry))
+		if !s.isPending(req.Key) {
+			// The request expired while waiting
+			return
+		}
+
+		if !s.breakerAllows(service) {
+			s.log.Printf("Circuit breaker of %s open: request %s failed\n", service, req.ID)
+			s.completeDestination(req.Key, req.ID, service, "error")
+			return
+		}
+
+		dest = s.failover(req, service, failed)
+		s.log.Printf("Retrying request %s on %s (retry %d)\n", req.ID, dest, retry+1)
+		if s.metrics != nil {
+			s.metrics.SendRetry()
+		}
+	}
+}
+
+// failover chooses an instance of the service that did not fail yet,
+// or any instance if all of them failed
+func (s *Service) failover(req network.Request, service string, failed []string) string {
+	instances, err := s.lookup(service)
+	if err != nil || len(instances) == 0 {
+		instances = []discovery.InstanceInfo{{Address: failed[len(failed)-1]}}
+	}
+
+	candidates := []discovery.InstanceInfo{}
+	for _, instance := range instances {
+		if !contains(failed, instance.Address) {
+			candidates = append(candidates, instance)
+		}
+	}
+	if len(candidates) == 0 {
+		return s.balancers.choose(req, service, instances)
+	}
+
+	return s.balancers.choose(req, service, candidates)
+}
+
+func (s *Service) isPending(key string) bool {
+	s.mutex_r.Lock()
+	_, ok := s.requests[key]
+	s.mutex_r.Unlock()
+	return ok
+}
+
+func failureStatus(err error) string {
+	if err == network.ErrOverloaded {
+		return "rejected"
+	}
+	return "error"
+}
+
+func contains(list []string, item string) bool {
+	for _, s := range list {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+func TestRetryDelay(t *testing.T) {
+	p := retryPolicy{
+		retries:    3,
+		backoff:    time.Duration(10) * time.Millisecond,
+		maxBackoff: time.Duration(100) * time.Millisecond,
+	}
+
+	tests := []struct {
+		retry int
+		max   time.Duration
+	}{
+		{0, time.Duration(10) * time.Millisecond},
+		{1, time.Duration(20) * time.Millisecond},
+		{3, time.Duration(80) * time.Millisecond},
+		// The backoff is capped, even when shifting overflows
+		{4, time.Duration(100) * time.Millisecond},
+		{40, time.Duration(100) * time.Millisecond},
+		{100, time.Duration(100) * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		for i := 0; i < 1000; i++ {
+			if d := p.delay(test.retry); d < 0 || d >= test.max {
+				t.Fatalf("delay(%d) = %v, want between 0 and %v", test.retry, d, test.max)
+			}
+		}
+	}
+
+	if d := (retryPolicy{}).delay(0); d != 0 {
+		t.Errorf("delay() without backoff = %v, want 0", d)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	g := newGraph()
+	s := g.service(t, Config{Name: "a", Workload: "none"})
+	for _, name := range []string{"b1", "b2", "b3"} {
+		g.register(t, "b", network.MemoryAddress(name))
+	}
+
+	req := network.Request{ID: "1", Key: "1"}
+	failed := []string{network.MemoryAddress("b1"), network.MemoryAddress("b3")}
+	for i := 0; i < 100; i++ {
+		if dest := s.failover(req, "b", failed); dest != network.MemoryAddress("b2") {
+			t.Fatalf("failover() = %s, want the only instance that did not fail", dest)
+		}
+	}
+
+	// When every instance failed any of them is retried
+	failed = append(failed, network.MemoryAddress("b2"))
+	if dest := s.failover(req, "b", failed); !contains(failed, dest) {
+		t.Errorf("failover() = %s, want one of %v", dest, failed)
+	}
+
+	// Without instances the last one is retried
+	if dest := s.failover(req, "c", []string{"x"}); dest != "x" {
+		t.Errorf("failover() without instances = %s, want x", dest)
+	}
+}
+
+func TestRetryOnAnotherInstance(t *testing.T) {
+	tests := []struct {
+		retries int
+		want    string
+	}{
+		{0, "error"},
+		{1, "done"},
+	}
+
+	for _, test := range tests {
+		g := newGraph()
+		// The only instance of b is down
+		g.register(t, "b", network.MemoryAddress("b-down"))
+		entry := g.start(t, Config{Name: "a", Workload: "none", Destinations: []string{"b"}, Retries: test.retries,
+			RetryBackoff: time.Millisecond})
+		c := g.client(t)
+		if test.retries > 0 {
+			g.start(t, Config{Name: "b", Workload: "none"})
+		}
+
+		// With a retry the request fails over to the instance that is up
+		for i := 0; i < 5; i++ {
+			if response := c.send(t, entry); response.Body != test.want {
+				t.Errorf("retries %d: response = %q, want %s", test.retries, response.Body, test.want)
+			}
+		}
+		g.stop()
+	}
+}
@@ -2,13 +2,11 @@ package app
 
 import (
 	"errors"
+	"io/ioutil"
 	"log"
-	"math/rand"
 	"net/http"
 	"os"
 	"os/signal"
-	"runtime"
-	"strconv"
 	"sync"
 	"syscall"
 	"time"
@@ -16,400 +14,554 @@ import (
 	"github.com/elleFlorio/mu-sim/discovery"
 	"github.com/elleFlorio/mu-sim/metric"
 	"github.com/elleFlorio/mu-sim/network"
+	"github.com/elleFlorio/mu-sim/tracing"
 	"github.com/elleFlorio/mu-sim/worker"
 )
 
+// Config describes the behaviour of a service
+type Config struct {
+	Name         string
+	Workload     string
+	Destinations []string
+	// How the requests are sent to the destinations: Quorum is
+	// used by the quorum mode and Weights by the weighted-one mode
+	FanOut  string
+	Quorum  int
+	Weights []float64
+	// Load balancing strategies: "strategy" for all the
+	// destinations or "service=strategy" for a single one
+	Balancers []string
+	// Record of the instance in the registry: its zone, version and
+	// weight (0 if unknown). With zone affinity the requests are sent
+	// to the instances in the same zone, if there are any.
+	Zone          string
+	Version       string
+	Weight        float64
+	ZoneAffinity  bool
+	Blocking      float64
+	Workers       int
+	QueueLength   int
+	Admission     string
+	CoDelTarget   time.Duration
+	CoDelInterval time.Duration
+	Timeout       time.Duration
+	Deadline      time.Duration
+	// Retries of a failed dispatch to a destination, after an
+	// exponential backoff (with jitter) between backoff and max backoff
+	Retries         int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// Consecutive failures of a destination that open its
+	// circuit breaker (0 disables the breakers)
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	FaultErrorRate   float64
+	FaultLatency     time.Duration
+	FaultDropRate    float64
+	FaultCrashAfter  int
+	Memory           string
+	MemoryLeak       float64
+	Disk             string
+	DiskDir          string
+	DiskBlock        string
+	DiskSync         string
+	DiskPattern      string
+}
+
+// Runtime holds the environment a service runs in: how it is reached,
+// how it finds the other services and where it sends its metrics
+type Runtime struct {
+	Address   string
+	Transport network.Transport
+	Registry  discovery.Registry
+	Metrics   *metric.Recorder
+	Tracer    *tracing.Tracer
+	Logger    *log.Logger
+}
+
 type ServiceParams struct {
-	EtcdAddress   string
+	Discovery     discovery.Config
 	InfluxAddress string
 	InfluxDbName  string
 	InfluxUser    string
 	InfluxPwd     string
+	Tracing       tracing.Config
 	Ip            string
 	Port          string
-	Name          string
-	Workload      string
-	Destinations  []string
+	Config
 }
 
-const (
-	messagePath  = "/message"
-	responsePath = "/response"
-)
+// Service is a simulated microservice
+type Service struct {
+	name      string
+	address   string
+	job       worker.Job
+	cfg       Config
+	transport network.Transport
+	registry  discovery.Registry
+	metrics   *metric.Recorder
+	tracer    *tracing.Tracer
+	log       *log.Logger
+	requests  map[string]network.Request
+	jobs      map[string]network.Request
+	counter   int
+	mutex_c   sync.Mutex
+	mutex_r   sync.Mutex
+	mutex_w   sync.Mutex
+	mutex_cfg sync.RWMutex
+	queue     *jobQueue
+	timeout   time.Duration
+	deadline  time.Duration
+	retry     retryPolicy
+	breakers  *circuitBreakers
+	balancers *loadBalancers
+	lookups   lookupStats
+	faults    *faultInjector
+	ch_done   chan network.Request
+	ch_stop   chan struct{}
+	ch_quit   chan struct{}
+	ch_crash  chan struct{}
+	stopOnce  sync.Once
+	crashOnce sync.Once
+}
 
 var (
-	name         string
-	destinations []string
-	workload     string
-	useMetrics   bool
-	requests     map[string]network.Request
-	jobs         map[string]network.Request
-	counter      = 1
-	mutex_c      = &sync.Mutex{}
-	mutex_r      = &sync.Mutex{}
-	mutex_w      = &sync.Mutex{}
-	ch_req       chan network.Request
-	ch_stop      chan struct{}
-
 	ErrNoDestinations = errors.New("No destinations available")
+	ErrUnknownRequest = errors.New("Cannot find request ID in history")
 )
 
-func init() {
-	requests = make(map[string]network.Request)
-	jobs = make(map[string]network.Request)
-	ch_req = make(chan network.Request)
-	ch_stop = make(chan struct{})
+func NewService(cfg Config, rt Runtime) (*Service, error) {
+	job, err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	logger := rt.Logger
+	if logger == nil {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	return &Service{
+		name:      cfg.Name,
+		address:   rt.Address,
+		job:       job,
+		cfg:       cfg,
+		transport: rt.Transport,
+		registry:  rt.Registry,
+		metrics:   rt.Metrics,
+		tracer:    rt.Tracer,
+		log:       logger,
+		requests:  make(map[string]network.Request),
+		jobs:      make(map[string]network.Request),
+		counter:   1,
+		queue:     newJobQueue(cfg.Workers, cfg.QueueLength, cfg.admission(), cfg.coDelTarget(), cfg.coDelInterval()),
+		timeout:   cfg.timeout(),
+		deadline:  cfg.Deadline,
+		retry:     cfg.retryPolicy(),
+		breakers:  newCircuitBreakers(cfg.BreakerThreshold, cfg.breakerCooldown(), cfg.timeout()),
+		balancers: newLoadBalancers(cfg.Balancers, cfg.affinityZone()),
+		faults:    &faultInjector{faults: cfg.faults()},
+		ch_done:   make(chan network.Request),
+		ch_stop:   make(chan struct{}),
+		ch_quit:   make(chan struct{}),
+		ch_crash:  make(chan struct{}),
+	}, nil
 }
 
-func StartService(params ServiceParams) {
-	var err error
-	name = params.Name
-	destinations = params.Destinations
-	workload = params.Workload
+// Validate checks that the configuration describes a valid
+// service, returning the job computed for every request
+func (cfg Config) Validate() (worker.Job, error) {
+	if cfg.Workers < 0 || cfg.QueueLength < 0 {
+		return worker.Job{}, errors.New("Workers and queue length cannot be negative")
+	}
 
-	log.Println("Service: ", name)
-	log.Println("Address: ", params.Ip)
-	log.Println("Port: ", params.Port)
-	log.Println("Workload: ", workload)
-	log.Println("Destinations: ", destinations)
+	if !validPolicy(cfg.admission()) {
+		return worker.Job{}, ErrInvalidPolicy
+	}
+	if cfg.CoDelTarget < 0 || cfg.CoDelInterval < 0 {
+		return worker.Job{}, errors.New("CoDel target and interval cannot be negative")
+	}
 
-	err = discovery.InitializeEtcd(params.EtcdAddress)
-	if err != nil {
-		log.Fatalln("Cannot connect to etcd server at ", params.EtcdAddress)
+	if cfg.Timeout < 0 || cfg.Deadline < 0 {
+		return worker.Job{}, errors.New("Timeout and deadline cannot be negative")
 	}
-	log.Println("Connected to etcd server at ", params.EtcdAddress)
 
-	myAddress := network.GenerateAddress(params.Ip, params.Port)
-	err = discovery.RegisterToEtcd(params.Name, myAddress)
-	if err != nil {
-		log.Fatalln("Cannot register to etcd server", params.EtcdAddress)
+	if cfg.Retries < 0 || cfg.RetryBackoff < 0 || cfg.RetryMaxBackoff < 0 {
+		return worker.Job{}, errors.New("Retries and retry backoff cannot be negative")
 	}
-	log.Println("Registered to etcd server")
 
-	startSigsMonitor(ch_stop)
-	keepAlive(ch_stop)
-	startJobsManager(ch_req)
-	initializeMetricService(params)
+	if cfg.BreakerThreshold < 0 || cfg.BreakerCooldown < 0 {
+		return worker.Job{}, errors.New("Breaker threshold and cooldown cannot be negative")
+	}
 
-	http.HandleFunc(responsePath, readResponse)
-	http.HandleFunc(messagePath, readMessage)
+	if _, err := ParseEdges(cfg.Destinations); err != nil {
+		return worker.Job{}, err
+	}
+	if err := cfg.validateFanOut(); err != nil {
+		return worker.Job{}, err
+	}
 
-	log.Println("Waiting for requests...")
-	log.Fatal(http.ListenAndServe(params.Port, nil))
-}
+	if err := validateBalancers(cfg.Balancers); err != nil {
+		return worker.Job{}, err
+	}
+	if cfg.Weight < 0 {
+		return worker.Job{}, errors.New("Weight cannot be negative")
+	}
 
-func keepAlive(ch_stop chan struct{}) {
-	go discovery.KeepAlive(ch_stop)
-}
+	if err := cfg.faults().Validate(); err != nil {
+		return worker.Job{}, err
+	}
 
-func startJobsManager(ch_req chan network.Request) {
-	go jobsManager(ch_req)
+	return newJob(cfg)
 }
 
-func jobsManager(ch_req chan network.Request) {
-	log.Println("Started work manager. Waiting for work to do...")
-	ch_done := make(chan network.Request)
-	for {
-		select {
-		case req := <-ch_req:
-			log.Println("Starting new worker on request ", req.ID)
-			addReqToWorks(req)
-			go worker.Work(workload, req, ch_done)
-		case reqDone := <-ch_done:
-			log.Printf("Request %s computed", reqDone.ID)
-			log.Println("service " + name + " " + "execution_time:" + strconv.FormatFloat(reqDone.ExecTimeMs, 'f', 2, 64) + "ms")
-			finalizeReq(reqDone)
-			removeReqFromWorks(reqDone.ID)
-			if useMetrics {
-				metric.SendExecutionTime(reqDone.ExecTimeMs)
-			}
-		}
+func (cfg Config) admission() string {
+	if cfg.Admission == "" {
+		return PolicyReject
 	}
+	return cfg.Admission
 }
 
-func startSigsMonitor(ch_stop chan struct{}) {
-	go sigsMonitor(ch_stop)
+func (cfg Config) coDelTarget() time.Duration {
+	if cfg.CoDelTarget == 0 {
+		return defaultCoDelTarget
+	}
+	return cfg.CoDelTarget
 }
 
-func sigsMonitor(ch_stop chan struct{}) {
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-
-	for {
-		select {
-		case <-sigs:
-			go shutDown(ch_stop)
-		}
+func (cfg Config) coDelInterval() time.Duration {
+	if cfg.CoDelInterval == 0 {
+		return defaultCoDelInterval
 	}
+	return cfg.CoDelInterval
 }
 
-func shutDown(ch_stop chan struct{}) {
-	log.Println("Received shutdown signal")
-	ch_stop <- struct{}{}
-	log.Println("Stopped keep alive goroutine")
-	discovery.UnregisterFromEtcd()
-	log.Println("Unregistered from etcd")
-	for isServiceWorking() {
-		log.Println("Waiting for jobs to complete...")
-		time.Sleep(time.Duration(1) * time.Second)
-	}
-	for isServiceWaiting() {
-		log.Println("Waiting for responses to requests...")
-		time.Sleep(time.Duration(1) * time.Second)
+func (cfg Config) timeout() time.Duration {
+	if cfg.Timeout == 0 {
+		return defaultTimeout
 	}
-	log.Fatalln("Done. Shutting down")
+	return cfg.Timeout
 }
 
-func isServiceWorking() bool {
-	mutex_w.Lock()
-	jobsInProgress := len(jobs)
-	mutex_w.Unlock()
-
-	if jobsInProgress != 0 {
-		return true
+func (cfg Config) retryPolicy() retryPolicy {
+	policy := retryPolicy{
+		retries:    cfg.Retries,
+		backoff:    cfg.RetryBackoff,
+		maxBackoff: cfg.RetryMaxBackoff,
 	}
-
-	return false
+	if policy.backoff == 0 {
+		policy.backoff = defaultRetryBackoff
+	}
+	if policy.maxBackoff == 0 {
+		policy.maxBackoff = defaultRetryMaxBackoff
+	}
+	return policy
 }
 
-func isServiceWaiting() bool {
-	mutex_r.Lock()
-	requestsPending := len(requests)
-	mutex_r.Unlock()
-
-	if requestsPending != 0 {
-		return true
+func (cfg Config) breakerCooldown() time.Duration {
+	if cfg.BreakerCooldown == 0 {
+		return defaultBreakerCooldown
 	}
-
-	return false
+	return cfg.BreakerCooldown
 }
 
-func initializeMetricService(params ServiceParams) {
+// newJob parses the job of the service from the configuration
+func newJob(cfg Config) (worker.Job, error) {
 	var err error
-	config := metric.InfluxConfig{
-		params.InfluxAddress,
-		params.InfluxDbName,
-		params.InfluxUser,
-		params.InfluxPwd,
-	}
-	useMetrics, err = metric.Initialize(params.Name, params.Workload, params.Ip, config)
+	job := worker.Job{}
+
+	job.Workload, err = worker.ParseWorkload(cfg.Workload)
 	if err != nil {
-		log.Printf("Error: %s; failded to initialize metric service. Metrics won't be recorded", err.Error())
+		return worker.Job{}, err
 	}
-}
 
-func addReqToWorks(req network.Request) {
-	mutex_w.Lock()
-	jobs[req.ID] = req
-	mutex_w.Unlock()
-}
-
-func removeReqFromWorks(id string) {
-	mutex_w.Lock()
-	delete(jobs, id)
-	mutex_w.Unlock()
-}
+	if cfg.Blocking < 0 || cfg.Blocking > 1 {
+		return worker.Job{}, errors.New("Blocking must be a fraction between 0 and 1")
+	}
+	job.Blocking = cfg.Blocking
 
-func finalizeReq(reqDone network.Request) {
-	if reqDone.To != "" {
-		err := sendMessageToSpecificService(reqDone.ID, reqDone.To)
+	if cfg.Memory != "" {
+		job.Memory.Size, err = worker.ParseSize(cfg.Memory)
 		if err != nil {
-			log.Println("Cannot dispatch message to service", reqDone.To)
-			return
+			return worker.Job{}, err
 		}
-		addRequestToHistory(reqDone)
-	} else {
-		if len(destinations) > 0 {
-			errCounter := sendMessageToDestinations(reqDone.ID)
-			if errCounter < len(destinations) {
-				// This is for requests to multiple destinations
-				// because I have to wait till every destination
-				// responde me before consider the request complete
-				reqCounter := len(destinations) - errCounter
-				reqDone.Counter = reqCounter
-				addRequestToHistory(reqDone)
-			}
-			if errCounter > 0 {
-				log.Println("Cannot dispatch message to all the destinations")
-				if errCounter == len(destinations) {
-					respondeToRequest(reqDone.From, reqDone.ID, "done")
-				}
-				return
-			}
-		} else {
-			respondeToRequest(reqDone.From, reqDone.ID, "done")
+	}
+	if cfg.MemoryLeak < 0 || cfg.MemoryLeak > 1 {
+		return worker.Job{}, errors.New("Memory leak must be a fraction between 0 and 1")
+	}
+	job.Memory.Leak = cfg.MemoryLeak
+
+	if cfg.Disk != "" {
+		job.Disk, err = newDiskLoad(cfg)
+		if err != nil {
+			return worker.Job{}, err
 		}
 	}
+
+	return job, nil
 }
 
-func readMessage(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+func newDiskLoad(cfg Config) (worker.DiskLoad, error) {
+	var err error
+	disk := worker.DiskLoad{
+		Dir:       cfg.DiskDir,
+		BlockSize: 4096,
+		Sync:      cfg.DiskSync,
+		Pattern:   cfg.DiskPattern,
+	}
 
-	// Create the request
-	req, err := createReq(r)
+	disk.Size, err = worker.ParseSize(cfg.Disk)
 	if err != nil {
-		log.Println("Cannot read message")
-		w.WriteHeader(422)
-		return
+		return worker.DiskLoad{}, err
+	}
+	if cfg.DiskBlock != "" {
+		disk.BlockSize, err = worker.ParseSize(cfg.DiskBlock)
+		if err != nil {
+			return worker.DiskLoad{}, err
+		}
+	}
+	if disk.Sync == "" {
+		disk.Sync = worker.SyncNone
+	}
+	if disk.Pattern == "" {
+		disk.Pattern = worker.PatternSequential
 	}
 
-	// Start work
-	ch_req <- req
-
-	w.WriteHeader(http.StatusCreated)
+	return disk, disk.Validate()
 }
 
-func createReq(r *http.Request) (network.Request, error) {
-	var err error
-	var requestID string
-	var start = time.Now()
+// StartService starts a service reachable through HTTP
+// and registered to etcd, then waits for a shutdown signal
+func StartService(params ServiceParams) {
+	log.Println("Service: ", params.Name)
+	log.Println("Address: ", params.Ip)
+	log.Println("Port: ", params.Port)
+	log.Println("Workload: ", params.Workload)
+	if params.Blocking > 0 {
+		log.Println("Blocking: ", params.Blocking)
+	}
+	if params.Workers > 0 {
+		log.Println("Workers: ", params.Workers)
+		log.Println("Admission policy: ", params.admission())
+	}
+	log.Println("Timeout: ", params.timeout())
+	if params.Deadline > 0 {
+		log.Println("Deadline: ", params.Deadline)
+	}
+	if params.Retries > 0 {
+		log.Println("Retries: ", params.Retries)
+	}
+	if params.BreakerThreshold > 0 {
+		log.Println("Circuit breaker threshold: ", params.BreakerThreshold)
+	}
+	if faults := params.faults(); faults != (Faults{}) {
+		log.Printf("Injected faults: %+v\n", faults)
+	}
+	if params.Memory != "" {
+		log.Println("Memory: ", params.Memory)
+	}
+	if params.Disk != "" {
+		log.Println("Disk: ", params.Disk)
+	}
+	log.Println("Destinations: ", params.Destinations)
+	if len(params.Destinations) > 1 {
+		log.Println("Fan-out: ", params.fanOut())
+	}
+	if len(params.Balancers) > 0 {
+		log.Println("Load balancing: ", params.Balancers)
+	}
+	if params.Zone != "" {
+		log.Println("Zone: ", params.Zone)
+	}
+	if params.Version != "" {
+		log.Println("Version: ", params.Version)
+	}
 
-	//read request
-	message, err := network.ReadMessage(r)
+	registry, err := discovery.NewRegistry(params.Discovery)
 	if err != nil {
-		log.Println("Cannot read message")
-		return network.Request{}, err
+		log.Fatalln("Cannot find the instances through the", params.Discovery.Describe()+":", err)
 	}
-	requestID = message.Args
-	if requestID == "" {
-		log.Println("New request, generating ID")
-		requestID = strconv.Itoa(readAndIncrementCounter())
+	log.Println("Discovering the instances through the", params.Discovery.Describe())
+
+	rt := Runtime{
+		Address:   network.GenerateAddress(params.Ip, params.Port),
+		Transport: network.NewHTTPTransport(),
+		Registry:  registry,
+		Metrics:   initializeMetricService(params),
+		Logger:    log.New(os.Stderr, "", log.LstdFlags),
+	}
+	exporter := initializeTracing(params)
+	if exporter != nil {
+		rt.Tracer = tracing.NewTracer(params.Name, exporter)
 	}
-	log.Printf("Received request %s from %s\n", requestID, message.Sender)
 
-	toService, err := network.ReadParam("service", r)
+	service, err := NewService(params.Config, rt)
 	if err != nil {
-		log.Println("Cannot read param 'service'")
+		log.Fatalln("Cannot create service: ", err)
+	}
+	if err = service.Start(); err != nil {
+		log.Fatalln("Cannot start service: ", err)
 	}
 
-	req := network.Request{
-		ID:         requestID,
-		From:       message.Sender,
-		To:         toService,
-		Counter:    len(destinations),
-		Start:      start,
-		ExecTimeMs: 0,
+	select {
+	case <-shutdownSignal():
+		log.Println("Received shutdown signal")
+	case <-service.Crashed():
+		if exporter != nil {
+			rt.Tracer.Stop()
+			exporter.Close()
+		}
+		log.Fatalln("Service crashed")
+	}
+	service.Stop()
+	if exporter != nil {
+		rt.Tracer.Stop()
+		exporter.Close()
 	}
+	log.Fatalln("Done. Shutting down")
+}
 
-	return req, nil
+// record describes the instance to the registry
+func (s *Service) record() discovery.InstanceInfo {
+	return discovery.InstanceInfo{
+		Address:  s.address,
+		Zone:     s.cfg.Zone,
+		Version:  s.cfg.Version,
+		Weight:   s.cfg.Weight,
+		Capacity: s.cfg.Workers,
+		Started:  time.Now(),
+	}
 }
 
-func sendMessageToSpecificService(requestID string, service string) error {
-	instances, err := discovery.GetAvailableInstances(service)
+// Start registers the service and starts serving requests
+func (s *Service) Start() error {
+	err := s.registry.Register(s.name, s.record())
 	if err != nil {
-		log.Println("Cannot dispatch message to service ", service)
+		s.log.Println("Cannot register service", s.name)
 		return err
 	}
-	destination := getDestination(instances)
-	sendReqToDest(requestID, destination)
-	return nil
-}
+	s.log.Println("Registered as", s.address)
 
-func sendMessageToDestinations(requestID string) int {
-	errCounter := 0
+	go s.registry.KeepAlive(s.ch_stop)
+	go s.jobsManager()
+	go s.expireRequests()
+	if s.metrics != nil {
+		go s.reportLookups()
+	}
 
-	for _, service := range destinations {
-		instances, err := discovery.GetAvailableInstances(service)
-		if err != nil {
-			log.Println("Cannot dispatch message to service ", service)
-			errCounter++
-			break
-		}
-		destination := getDestination(instances)
-		sendReqToDest(requestID, destination)
+	err = s.transport.Listen(s.address, s)
+	if err != nil {
+		s.log.Println("Cannot listen on", s.address)
+		close(s.ch_stop)
+		close(s.ch_quit)
+		s.registry.Unregister()
+		return err
 	}
 
-	return errCounter
+	if router, ok := s.transport.(network.Router); ok {
+		router.Handle(s.address, faultsPath, http.HandlerFunc(s.serveFaults))
+		router.Handle(s.address, adminConfigPath, http.HandlerFunc(s.serveAdminConfig))
+	}
+
+	s.log.Println("Waiting for requests...")
+	return nil
 }
 
-func getDestination(instances []string) string {
-	if len(instances) == 1 {
-		return instances[0]
+// Stop unregisters the service and returns once every job
+// has been computed and every pending request has been answered
+func (s *Service) Stop() {
+	s.halt()
+	select {
+	case <-s.ch_crash:
+		// A crashed service does not answer its pending requests
+	default:
+		for s.isServiceWorking() {
+			s.log.Println("Waiting for jobs to complete...")
+			time.Sleep(time.Duration(1) * time.Second)
+		}
+		for s.isServiceWaiting() {
+			s.log.Println("Waiting for responses to requests...")
+			time.Sleep(time.Duration(1) * time.Second)
+		}
 	}
-
-	return instances[rand.Intn(len(instances))]
+	close(s.ch_quit)
+	s.transport.Close(s.address)
 }
 
-func sendReqToDest(reqID string, dest string) {
-	go network.Send(dest, "do", reqID, network.GetMyAddress(), false)
-	log.Printf("Request %s sent to %s\n", reqID, dest)
+// halt stops the keep alive and unregisters the service,
+// so it won't receive requests anymore
+func (s *Service) halt() {
+	s.stopOnce.Do(func() {
+		close(s.ch_stop)
+		s.log.Println("Stopped keep alive goroutine")
+		s.registry.Unregister()
+		s.log.Println("Unregistered from registry")
+	})
 }
 
-func readAndIncrementCounter() int {
-	mutex_c.Lock()
-	c := counter
-	counter++
-	mutex_c.Unlock()
-	runtime.Gosched()
+func (s *Service) Name() string {
+	return s.name
+}
 
-	return c
+func (s *Service) Address() string {
+	return s.address
 }
 
-func addRequestToHistory(req network.Request) {
-	mutex_r.Lock()
-	requests[req.ID] = req
-	mutex_r.Unlock()
-	runtime.Gosched()
-	log.Printf("Added request %s to history\n", req.ID)
+func shutdownSignal() <-chan os.Signal {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	return sigs
 }
 
-func respondeToRequest(dest string, reqId string, status string) {
-	network.Send(dest, status, reqId, network.GetMyAddress(), true)
-	log.Printf("Response to request %s sent to %s\n", reqId, dest)
+func (s *Service) isServiceWorking() bool {
+	s.mutex_w.Lock()
+	jobsInProgress := len(s.jobs)
+	s.mutex_w.Unlock()
+
+	if jobsInProgress != 0 {
+		return true
+	}
+
+	return false
 }
 
-func readResponse(w http.ResponseWriter, r *http.Request) {
-	var err error
-	var respTimeMs float64
+func (s *Service) isServiceWaiting() bool {
+	s.mutex_r.Lock()
+	requestsPending := len(s.requests)
+	s.mutex_r.Unlock()
+
+	if requestsPending != 0 {
+		return true
+	}
 
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	return false
+}
 
-	message, err := network.ReadMessage(r)
+func initializeMetricService(params ServiceParams) *metric.Recorder {
+	config := metric.InfluxConfig{
+		Address:  params.InfluxAddress,
+		DBname:   params.InfluxDbName,
+		Username: params.InfluxUser,
+		Password: params.InfluxPwd,
+	}
+	recorder, err := metric.New(params.Name, params.Workload, params.Ip, config)
 	if err != nil {
-		log.Println("Cannot read message")
-		w.WriteHeader(422)
-		return
-	}
-	log.Println("Received response from ", message.Sender)
-
-	reqId := message.Args
-	if req, ok := requests[reqId]; ok {
-		respTimeMs = time.Since(req.Start).Seconds() * 1000
-		complete := updateRequestInHistory(reqId)
-		if complete {
-			respondeToRequest(req.From, req.ID, message.Body)
-		}
-	} else {
-		log.Println("Cannot find request ID in history")
-		w.WriteHeader(422)
-		return
-	}
-	if message.Body == "done" {
-		log.Println("service " + name + " " + "response_time" + ":" + strconv.FormatFloat(respTimeMs, 'f', 2, 64) + "ms")
-		if useMetrics {
-			metric.SendResponseTime(respTimeMs)
-		}
-	} else {
-		log.Println("Error: request lost.")
+		log.Printf("Error: %s; failded to initialize metric service. Metrics won't be recorded", err.Error())
+		return nil
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	return recorder
 }
 
-func updateRequestInHistory(reqId string) bool {
-	deleted := false
-	mutex_r.Lock()
-	req := requests[reqId]
-	req.Counter -= 1
-	if req.Counter <= 0 {
-		delete(requests, reqId)
-		deleted = true
-		log.Printf("Removed request %s from history\n", reqId)
-	} else {
-		requests[reqId] = req
-		log.Printf("Updated counter  of request %s: %d\n", reqId, req.Counter)
-	}
-	mutex_r.Unlock()
-	runtime.Gosched()
-	return deleted
+func initializeTracing(params ServiceParams) tracing.Exporter {
+	exporter, err := tracing.NewExporter(params.Tracing)
+	if err == tracing.ErrNotConfigured {
+		return nil
+	}
+	if err != nil {
+		log.Printf("Error: %s; failed to initialize tracing. Spans won't be recorded", err.Error())
+		return nil
+	}
+
+	return exporter
 }
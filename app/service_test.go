@@ -0,0 +1,165 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/discovery"
+	"github.com/elleFlorio/mu-sim/network"
+	"github.com/elleFlorio/mu-sim/tracing"
+)
+
+const responseTimeout = time.Duration(5) * time.Second
+
+// client sends requests to the services and receives their responses
+type client struct {
+	address   string
+	transport *network.MemoryTransport
+	responses chan network.Message
+}
+
+func (c *client) HandleMessage(message network.Message, service string) error {
+	return network.ErrUnprocessable
+}
+
+func (c *client) HandleResponse(message network.Message) error {
+	c.responses <- message
+	return nil
+}
+
+// send sends a new request to the address and waits for its response
+func (c *client) send(t *testing.T, address string) network.Message {
+	return c.request(t, address, network.Message{Body: "do"}, "")
+}
+
+// request sends the message to the address, asking to forward it to
+// the service if not empty, and waits for the response
+func (c *client) request(t *testing.T, address string, message network.Message, service string) network.Message {
+	message.Sender = c.address
+	if err := c.transport.SendMessage(address, message, service); err != nil {
+		t.Fatalf("Cannot send the request to %s: %s", address, err)
+	}
+
+	select {
+	case response := <-c.responses:
+		return response
+	case <-time.After(responseTimeout):
+		t.Fatalf("No response from %s", address)
+		return network.Message{}
+	}
+}
+
+// silent is a service that never answers, keeping the requests it receives
+type silent struct {
+	messages chan network.Message
+}
+
+func (s *silent) HandleMessage(message network.Message, service string) error {
+	s.messages <- message
+	return nil
+}
+
+func (s *silent) HandleResponse(message network.Message) error {
+	return nil
+}
+
+// graph is a set of services running in memory, as in a simulation.
+// With an exporter every service traces its requests.
+type graph struct {
+	transport *network.MemoryTransport
+	directory *discovery.MemoryDirectory
+	exporter  tracing.Exporter
+	services  []*Service
+	tracers   []*tracing.Tracer
+}
+
+func newGraph() *graph {
+	return &graph{
+		transport: network.NewMemoryTransport(),
+		directory: discovery.NewMemoryDirectory(),
+	}
+}
+
+// service creates a service with the configuration, without starting it
+func (g *graph) service(t *testing.T, cfg Config) *Service {
+	rt := Runtime{
+		Address:   network.MemoryAddress(cfg.Name),
+		Transport: g.transport,
+		Registry:  g.directory.NewRegistry(),
+	}
+	if g.exporter != nil {
+		rt.Tracer = tracing.NewTracer(cfg.Name, g.exporter)
+		g.tracers = append(g.tracers, rt.Tracer)
+	}
+	s, err := NewService(cfg, rt)
+	if err != nil {
+		t.Fatalf("Cannot create %s: %s", cfg.Name, err)
+	}
+	return s
+}
+
+// start starts a service with the configuration, returning its address
+func (g *graph) start(t *testing.T, cfg Config) string {
+	s := g.service(t, cfg)
+	if err := s.Start(); err != nil {
+		t.Fatalf("Cannot start %s: %s", cfg.Name, err)
+	}
+	g.services = append(g.services, s)
+	return s.Address()
+}
+
+// register registers an instance of the service at the address
+func (g *graph) register(t *testing.T, name string, address string) {
+	if err := g.directory.NewRegistry().Register(name, discovery.InstanceInfo{Address: address}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// listen registers a service served by the handler
+func (g *graph) listen(t *testing.T, name string, h network.Handler) {
+	address := network.MemoryAddress(name)
+	g.register(t, name, address)
+	if err := g.transport.Listen(address, h); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (g *graph) client(t *testing.T) *client {
+	c := &client{
+		address:   network.MemoryAddress("client"),
+		transport: g.transport,
+		responses: make(chan network.Message, 10),
+	}
+	if err := g.transport.Listen(c.address, c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func (g *graph) stop() {
+	for _, s := range g.services {
+		s.Stop()
+	}
+	for _, tracer := range g.tracers {
+		tracer.Stop()
+	}
+}
+
+// newTestService creates a service that is not started
+func newTestService(t *testing.T, cfg Config) *Service {
+	return newGraph().service(t, cfg)
+}
+
+func TestServiceGraph(t *testing.T) {
+	g := newGraph()
+	defer g.stop()
+
+	entry := g.start(t, Config{Name: "a", Workload: "none", Destinations: []string{"b", "c"}})
+	g.start(t, Config{Name: "b", Workload: "none", Destinations: []string{"c"}})
+	g.start(t, Config{Name: "c", Workload: "none"})
+
+	response := g.client(t).send(t, entry)
+	if response.Body != "done" {
+		t.Errorf("response = %q, want done", response.Body)
+	}
+}
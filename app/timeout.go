@@ -0,0 +1,75 @@
+package app
+
+import (
+	"time"
+
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+const (
+	defaultTimeout = time.Duration(30) * time.Second
+	sweepInterval  = time.Duration(100) * time.Millisecond
+)
+
+// expiration computes when the service stops waiting for the
+// destinations of a request: after the timeout of the hop or at the
+// deadline of the sender, whichever comes first
+func (s *Service) expiration(req network.Request) time.Time {
+	expires := req.Dispatched.Add(s.timeout)
+	if !req.Deadline.IsZero() && req.Deadline.Before(expires) {
+		return req.Deadline
+	}
+	return expires
+}
+
+func expired(deadline time.Time, now time.Time) bool {
+	return !deadline.IsZero() && !now.Before(deadline)
+}
+
+// expireRequests periodically removes from the history the requests
+// whose destinations did not respond in time
+func (s *Service) expireRequests() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, req := range s.removeExpiredRequests(now) {
+				s.balancers.release(req.Key)
+				for _, service := range req.Waiting {
+					s.recordOutcome(service, false)
+				}
+				s.traceDownstream(req, "timeout")
+				s.timeoutReq(req)
+			}
+		case <-s.ch_quit:
+			return
+		}
+	}
+}
+
+func (s *Service) removeExpiredRequests(now time.Time) []network.Request {
+	expiredReqs := []network.Request{}
+
+	s.mutex_r.Lock()
+	for key, req := range s.requests {
+		if expired(req.Expires, now) {
+			delete(s.requests, key)
+			expiredReqs = append(expiredReqs, req)
+		}
+	}
+	s.mutex_r.Unlock()
+
+	return expiredReqs
+}
+
+// timeoutReq answers to a request that cannot be completed in time
+func (s *Service) timeoutReq(req network.Request) {
+	s.log.Printf("Request %s timed out\n", req.ID)
+	s.respondeToRequest(req, "timeout")
+	s.traceRequest(req, "timeout")
+	if s.metrics != nil {
+		s.metrics.SendTimeout()
+	}
+}
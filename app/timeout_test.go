@@ -0,0 +1,91 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+func TestExpiration(t *testing.T) {
+	dispatched := time.Now()
+	s := &Service{timeout: time.Second}
+
+	tests := []struct {
+		deadline time.Time
+		want     time.Time
+	}{
+		// Without deadline the service waits for its timeout
+		{time.Time{}, dispatched.Add(time.Second)},
+		{dispatched.Add(time.Duration(500) * time.Millisecond), dispatched.Add(time.Duration(500) * time.Millisecond)},
+		{dispatched.Add(time.Duration(2) * time.Second), dispatched.Add(time.Second)},
+	}
+
+	for _, test := range tests {
+		req := network.Request{Dispatched: dispatched, Deadline: test.deadline}
+		if got := s.expiration(req); !got.Equal(test.want) {
+			t.Errorf("expiration() with deadline %v = %v, want %v", test.deadline, got, test.want)
+		}
+	}
+}
+
+func TestCreateReqDeadline(t *testing.T) {
+	s := newTestService(t, Config{Name: "a", Workload: "none", Deadline: time.Second})
+
+	// The deadline of the sender is kept
+	deadline := time.Now().Add(time.Minute).Round(time.Millisecond)
+	req := s.createReq(network.Message{Body: "do", Deadline: deadline.UnixNano() / int64(time.Millisecond)}, "")
+	if !req.Deadline.Equal(deadline) {
+		t.Errorf("deadline = %v, want the deadline of the message %v", req.Deadline, deadline)
+	}
+
+	// A new request gets the deadline of the service
+	req = s.createReq(network.Message{Body: "do"}, "")
+	if !req.Deadline.Equal(req.Start.Add(time.Second)) {
+		t.Errorf("deadline = %v, want 1s after the arrival %v", req.Deadline, req.Start)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	g := newGraph()
+	defer g.stop()
+
+	b := &silent{messages: make(chan network.Message, 1)}
+	g.listen(t, "b", b)
+	timeout := time.Duration(100) * time.Millisecond
+	entry := g.start(t, Config{Name: "a", Workload: "none", Destinations: []string{"b"}, Timeout: timeout})
+
+	start := time.Now()
+	response := g.client(t).send(t, entry)
+	if response.Body != "timeout" {
+		t.Errorf("response = %q, want timeout", response.Body)
+	}
+
+	// The destination knows when the request expires
+	message := <-b.messages
+	deadline := time.Unix(0, message.Deadline*int64(time.Millisecond))
+	if deadline.Before(start) || deadline.After(start.Add(timeout+time.Second)) {
+		t.Errorf("deadline sent to the destination = %v, want about %v after %v", deadline, timeout, start)
+	}
+}
+
+func TestRequestExpiredOnArrival(t *testing.T) {
+	g := newGraph()
+	defer g.stop()
+
+	entry := g.start(t, Config{Name: "a", Workload: "none"})
+	expired := network.Message{Body: "do", Deadline: time.Now().Add(-time.Second).UnixNano() / int64(time.Millisecond)}
+	if response := g.client(t).request(t, entry, expired, ""); response.Body != "timeout" {
+		t.Errorf("response = %q, want timeout", response.Body)
+	}
+}
+
+func TestRequestUnknownService(t *testing.T) {
+	g := newGraph()
+	defer g.stop()
+
+	entry := g.start(t, Config{Name: "a", Workload: "none"})
+	if response := g.client(t).request(t, entry, network.Message{Body: "do"}, "missing"); response.Body != "error" {
+		t.Errorf("response = %q, want error", response.Body)
+	}
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/network"
+	"github.com/elleFlorio/mu-sim/tracing"
+)
+
+// traceJob records the time a request spent in the queue
+// and the time needed to compute it
+func (s *Service) traceJob(req network.Request) {
+	if s.tracer == nil {
+		return
+	}
+
+	execStart := req.Start.Add(msToDuration(req.QueueTimeMs))
+	queue := tracing.NewSpan(req.TraceID, tracing.NewSpanID(), req.SpanID, "queue", req.Start, execStart)
+	queue.Tags["queue.length"] = strconv.Itoa(req.QueueLength)
+	s.tracer.Record(queue)
+
+	execution := tracing.NewSpan(req.TraceID, tracing.NewSpanID(), req.SpanID, "execute",
+		execStart, execStart.Add(msToDuration(req.ExecTimeMs)))
+	if req.IOBytes > 0 {
+		execution.Tags["io.bytes"] = strconv.FormatInt(req.IOBytes, 10)
+		execution.Tags["io.time_ms"] = strconv.FormatFloat(req.IOTimeMs, 'f', 2, 64)
+	}
+	s.tracer.Record(execution)
+}
+
+// traceDownstream records the time spent waiting for the destinations
+func (s *Service) traceDownstream(req network.Request, status string) {
+	if s.tracer == nil || req.Dispatched.IsZero() {
+		return
+	}
+
+	downstream := tracing.NewSpan(req.TraceID, tracing.NewSpanID(), req.SpanID, "downstream", req.Dispatched, time.Now())
+	downstream.Tags["response"] = status
+	s.tracer.Record(downstream)
+}
+
+// traceRequest records the whole processing of a request,
+// from its arrival to its response
+func (s *Service) traceRequest(req network.Request, status string) {
+	if s.tracer == nil {
+		return
+	}
+
+	span := tracing.NewSpan(req.TraceID, req.SpanID, req.ParentSpan, "request", req.Start, time.Now())
+	span.Kind = tracing.KindServer
+	span.Tags["request.id"] = req.ID
+	span.Tags["response"] = status
+	if req.To != "" {
+		span.Tags["service"] = req.To
+	}
+	if status != "done" {
+		span.Tags["error"] = status
+	}
+	s.tracer.Record(span)
+}
+
+func msToDuration(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/tracing"
+)
+
+// recordingExporter keeps the exported spans
+type recordingExporter struct {
+	mutex sync.Mutex
+	spans []tracing.Span
+}
+
+func (e *recordingExporter) Export(spans []tracing.Span) error {
+	e.mutex.Lock()
+	e.spans = append(e.spans, spans...)
+	e.mutex.Unlock()
+	return nil
+}
+
+func (e *recordingExporter) Close() error {
+	return nil
+}
+
+// wait flushes the tracers until count spans are exported
+func (e *recordingExporter) wait(t *testing.T, tracers []*tracing.Tracer, count int) []tracing.Span {
+	deadline := time.Now().Add(responseTimeout)
+	for time.Now().Before(deadline) {
+		for _, tracer := range tracers {
+			tracer.Flush()
+		}
+		e.mutex.Lock()
+		spans := e.spans
+		e.mutex.Unlock()
+		if len(spans) >= count {
+			return spans
+		}
+		time.Sleep(time.Duration(10) * time.Millisecond)
+	}
+
+	t.Fatalf("Less than %d spans exported", count)
+	return nil
+}
+
+func TestTraceParenting(t *testing.T) {
+	exporter := &recordingExporter{}
+	g := newGraph()
+	g.exporter = exporter
+	defer g.stop()
+
+	entry := g.start(t, Config{Name: "a", Workload: "none", Destinations: []string{"b"}})
+	g.start(t, Config{Name: "b", Workload: "none"})
+	g.client(t).send(t, entry)
+
+	// a: request, queue, execute and downstream. b: request, queue and execute.
+	spans := exporter.wait(t, g.tracers, 7)
+	requests := map[string]tracing.Span{}
+	for _, span := range spans {
+		if span.Name == "request" {
+			requests[span.LocalEndpoint.ServiceName] = span
+		}
+	}
+	a, b := requests["a"], requests["b"]
+	if a.ParentID != "" {
+		t.Errorf("parent of the request span of a = %q, want a root span", a.ParentID)
+	}
+	if b.ParentID != a.ID || b.TraceID != a.TraceID {
+		t.Errorf("request span of b = %+v, want a child of %s in trace %s", b, a.ID, a.TraceID)
+	}
+
+	// The other spans are children of the request span of their service
+	for _, span := range spans {
+		if span.Name == "request" {
+			continue
+		}
+		parent := requests[span.LocalEndpoint.ServiceName]
+		if span.ParentID != parent.ID || span.TraceID != parent.TraceID {
+			t.Errorf("%s span of %s = %+v, want a child of %s", span.Name, span.LocalEndpoint.ServiceName, span, parent.ID)
+		}
+	}
+}
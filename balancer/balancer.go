@@ -0,0 +1,216 @@
+package balancer
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/discovery"
+)
+
+// Strategies of the balancers
+const (
+	Random           = "random"
+	RoundRobin       = "round-robin"
+	Weighted         = "weighted"
+	LeastOutstanding = "least-outstanding"
+	PowerOfTwo       = "p2c"
+	ConsistentHash   = "consistent-hash"
+	EWMA             = "ewma"
+)
+
+var ErrInvalidBalancer = errors.New("Invalid balancer")
+
+// Balancer chooses the instance of a destination that receives a request,
+// returning its address. Every chosen instance is followed by a call to
+// Done when the request is over, with its latency (0 if the request
+// failed before reaching the instance), so the balancers can track the
+// load of the instances.
+type Balancer interface {
+	Choose(instances []discovery.InstanceInfo, requestID string) string
+	Done(instance string, latency time.Duration)
+}
+
+// Parse creates the balancer described by the spec, in the form
+// name:param=value,param=value (e.g. "ewma:decay=5000"). The
+// instances of the weighted balancer are the params, with their
+// weight (e.g. "weighted:http://10.0.0.1:8080=3"); the others
+// weigh as registered.
+func Parse(spec string) (Balancer, error) {
+	name, args := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, args = spec[:i], spec[i+1:]
+	}
+
+	p, err := parseParams(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %s", ErrInvalidBalancer, spec, err)
+	}
+
+	b, err := newBalancer(name, p)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %s", ErrInvalidBalancer, spec, err)
+	}
+
+	return b, nil
+}
+
+func newBalancer(name string, p params) (Balancer, error) {
+	if name != Weighted {
+		if err := p.allow(paramsOf[name]...); err != nil {
+			return nil, err
+		}
+	}
+
+	switch name {
+	case Random:
+		return &randomBalancer{}, nil
+	case RoundRobin:
+		return &roundRobinBalancer{}, nil
+	case Weighted:
+		for instance, w := range p {
+			if w < 0 {
+				return nil, fmt.Errorf("negative weight of %s", instance)
+			}
+		}
+		return &weightedBalancer{weights: p}, nil
+	case LeastOutstanding:
+		return &leastOutstandingBalancer{outstanding: newOutstanding()}, nil
+	case PowerOfTwo:
+		return &powerOfTwoBalancer{outstanding: newOutstanding()}, nil
+	case ConsistentHash:
+		replicas := p.get("replicas", defaultReplicas)
+		if replicas < 1 {
+			return nil, errors.New("replicas must be at least 1")
+		}
+		return &consistentHashBalancer{replicas: int(replicas)}, nil
+	case EWMA:
+		decay := p.get("decay", defaultDecay)
+		if decay <= 0 {
+			return nil, errors.New("decay must be positive")
+		}
+		return newEWMABalancer(time.Duration(decay * float64(time.Millisecond))), nil
+	default:
+		return nil, errors.New("unknown balancer " + name)
+	}
+}
+
+// Params accepted by every balancer (but the weighted one)
+var paramsOf = map[string][]string{
+	ConsistentHash: {"replicas"},
+	EWMA:           {"decay"},
+}
+
+type params map[string]float64
+
+// parseParams parses the list param=value,param=value. The
+// name of the param is everything before the last "=",
+// so it can be the address of an instance.
+func parseParams(args string) (params, error) {
+	p := params{}
+	if args == "" {
+		return p, nil
+	}
+
+	for _, arg := range strings.Split(args, ",") {
+		i := strings.LastIndex(arg, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid param %q", arg)
+		}
+		value, err := strconv.ParseFloat(arg[i+1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of param %s", arg[:i])
+		}
+		p[arg[:i]] = value
+	}
+
+	return p, nil
+}
+
+func (p params) allow(names ...string) error {
+	for name := range p {
+		known := false
+		for _, n := range names {
+			if n == name {
+				known = true
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown param %s", name)
+		}
+	}
+	return nil
+}
+
+func (p params) get(name string, def float64) float64 {
+	if value, ok := p[name]; ok {
+		return value
+	}
+	return def
+}
+
+// randomBalancer chooses an instance uniformly at random
+type randomBalancer struct{}
+
+func (b *randomBalancer) Choose(instances []discovery.InstanceInfo, requestID string) string {
+	return instances[rand.Intn(len(instances))].Address
+}
+
+func (b *randomBalancer) Done(instance string, latency time.Duration) {}
+
+// roundRobinBalancer chooses the instances in turn
+type roundRobinBalancer struct {
+	mutex sync.Mutex
+	next  int
+}
+
+func (b *roundRobinBalancer) Choose(instances []discovery.InstanceInfo, requestID string) string {
+	b.mutex.Lock()
+	i := b.next % len(instances)
+	b.next = i + 1
+	b.mutex.Unlock()
+
+	return instances[i].Address
+}
+
+func (b *roundRobinBalancer) Done(instance string, latency time.Duration) {}
+
+// weightedBalancer chooses an instance with a probability proportional
+// to its weight: the one given to the balancer or, if missing, the one
+// the instance registered with. The instances without a weight weigh 1.
+type weightedBalancer struct {
+	weights params
+}
+
+func (b *weightedBalancer) weight(instance discovery.InstanceInfo) float64 {
+	def := 1.0
+	if instance.Weight > 0 {
+		def = instance.Weight
+	}
+	return b.weights.get(instance.Address, def)
+}
+
+func (b *weightedBalancer) Choose(instances []discovery.InstanceInfo, requestID string) string {
+	total := 0.0
+	for _, instance := range instances {
+		total += b.weight(instance)
+	}
+	if total == 0 {
+		return instances[rand.Intn(len(instances))].Address
+	}
+
+	x := rand.Float64() * total
+	for _, instance := range instances {
+		x -= b.weight(instance)
+		if x < 0 {
+			return instance.Address
+		}
+	}
+	return instances[len(instances)-1].Address
+}
+
+func (b *weightedBalancer) Done(instance string, latency time.Duration) {}
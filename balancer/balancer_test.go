@@ -0,0 +1,176 @@
+package balancer
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/discovery"
+)
+
+func instances(addresses ...string) []discovery.InstanceInfo {
+	list := make([]discovery.InstanceInfo, len(addresses))
+	for i, address := range addresses {
+		list[i] = discovery.InstanceInfo{Address: address}
+	}
+	return list
+}
+
+func TestParse(t *testing.T) {
+	valid := []string{
+		"random",
+		"round-robin",
+		"weighted",
+		"weighted:http://10.0.0.1:8080=3,http://10.0.0.2:8080=0",
+		"least-outstanding",
+		"p2c",
+		"consistent-hash",
+		"consistent-hash:replicas=10",
+		"ewma",
+		"ewma:decay=5000",
+	}
+	for _, spec := range valid {
+		if _, err := Parse(spec); err != nil {
+			t.Errorf("Parse(%q): unexpected error %s", spec, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"fastest",
+		"random:replicas=10",
+		"weighted:http://10.0.0.1:8080=-1",
+		"weighted:http://10.0.0.1:8080",
+		"weighted:http://10.0.0.1:8080=abc",
+		"consistent-hash:replicas=0",
+		"ewma:decay=0",
+		"ewma:window=10",
+	}
+	for _, spec := range invalid {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q), want an error", spec)
+		}
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	b, _ := Parse(RoundRobin)
+	list := instances("a", "b", "c")
+
+	for i, want := range []string{"a", "b", "c", "a", "b"} {
+		if got := b.Choose(list, strconv.Itoa(i)); got != want {
+			t.Errorf("Choose() #%d = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	tests := []struct {
+		spec      string
+		instances []discovery.InstanceInfo
+		want      string
+	}{
+		// Weights of the balancer
+		{"weighted:a=0,b=1", instances("a", "b"), "b"},
+		// Weights of the instances
+		{"weighted", []discovery.InstanceInfo{{Address: "a", Weight: 0}, {Address: "b", Weight: 1e9}}, "b"},
+		// The weights of the balancer come first
+		{"weighted:b=0", []discovery.InstanceInfo{{Address: "a"}, {Address: "b", Weight: 1e9}}, "a"},
+	}
+
+	for _, test := range tests {
+		b, err := Parse(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := map[string]int{}
+		for i := 0; i < 1000; i++ {
+			counts[b.Choose(test.instances, strconv.Itoa(i))]++
+		}
+		if counts[test.want] < 990 {
+			t.Errorf("%s: chosen %v, want nearly always %s", test.spec, counts, test.want)
+		}
+	}
+}
+
+func TestLeastOutstanding(t *testing.T) {
+	b, _ := Parse(LeastOutstanding)
+	list := instances("a", "b")
+
+	first := b.Choose(list, "1")
+	second := b.Choose(list, "2")
+	if first == second {
+		t.Fatalf("Choose() = %s twice, want the idle instance", first)
+	}
+
+	// Only the first one is still busy
+	b.Done(second, time.Millisecond)
+	if got := b.Choose(list, "3"); got != second {
+		t.Errorf("Choose() = %s, want the idle %s", got, second)
+	}
+}
+
+func TestPowerOfTwo(t *testing.T) {
+	b, _ := Parse(PowerOfTwo)
+	list := instances("a", "b")
+
+	// With two instances both are compared every time
+	busy := b.Choose(list, "1")
+	for i := 0; i < 10; i++ {
+		got := b.Choose(list, strconv.Itoa(i))
+		if got == busy {
+			t.Fatalf("Choose() = %s, want the less loaded instance", got)
+		}
+		b.Done(got, time.Millisecond)
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	b, _ := Parse(ConsistentHash)
+	list := instances("a", "b", "c")
+
+	chosen := map[string]string{}
+	counts := map[string]int{}
+	for i := 0; i < 300; i++ {
+		id := strconv.Itoa(i)
+		chosen[id] = b.Choose(list, id)
+		counts[chosen[id]]++
+	}
+	for _, instance := range []string{"a", "b", "c"} {
+		if counts[instance] < 50 {
+			t.Errorf("%s chosen %d times out of 300, want the requests spread", instance, counts[instance])
+		}
+	}
+
+	// The same request reaches the same instance, and removing an
+	// instance moves only the requests it received
+	list = instances("a", "b")
+	for id, before := range chosen {
+		got := b.Choose(list, id)
+		if before != "c" && got != before {
+			t.Errorf("request %s moved from %s to %s", id, before, got)
+		}
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	b, _ := Parse(EWMA)
+	list := instances("a", "b")
+
+	// Every instance is tried at first
+	first := b.Choose(list, "1")
+	b.Done(first, time.Duration(100)*time.Millisecond)
+	second := b.Choose(list, "2")
+	if second == first {
+		t.Fatalf("Choose() = %s twice, want every instance tried", first)
+	}
+	b.Done(second, time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		got := b.Choose(list, strconv.Itoa(i))
+		if got != second {
+			t.Fatalf("Choose() = %s, want the faster %s", got, second)
+		}
+		b.Done(got, time.Millisecond)
+	}
+}
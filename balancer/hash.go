@@ -0,0 +1,77 @@
+package balancer
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/discovery"
+)
+
+const defaultReplicas = 100
+
+// consistentHashBalancer places the instances on a ring, every instance
+// replicas times, and chooses the first instance following the hash of
+// the request ID. The same request always reaches the same instance, and
+// only a few requests move when the instances change.
+type consistentHashBalancer struct {
+	replicas int
+	mutex    sync.Mutex
+	key      string
+	ring     []ringPoint
+}
+
+type ringPoint struct {
+	hash     uint32
+	instance string
+}
+
+type byHash []ringPoint
+
+func (r byHash) Len() int           { return len(r) }
+func (r byHash) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r byHash) Less(i, j int) bool { return r[i].hash < r[j].hash }
+
+// hashOf spreads the FNV hash with the finalizer of MurmurHash3, as the
+// IDs of the requests often differ only in their last characters
+func hashOf(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	x := h.Sum32()
+	x ^= x >> 16
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	x *= 0xc2b2ae35
+	x ^= x >> 16
+	return x
+}
+
+func (b *consistentHashBalancer) Choose(instances []discovery.InstanceInfo, requestID string) string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// The ring is built again only when the instances change
+	addresses := discovery.Addresses(instances)
+	if key := strings.Join(addresses, " "); key != b.key {
+		b.key = key
+		b.ring = make([]ringPoint, 0, len(addresses)*b.replicas)
+		for _, instance := range addresses {
+			for i := 0; i < b.replicas; i++ {
+				b.ring = append(b.ring, ringPoint{hashOf(instance + "#" + strconv.Itoa(i)), instance})
+			}
+		}
+		sort.Sort(byHash(b.ring))
+	}
+
+	h := hashOf(requestID)
+	i := sort.Search(len(b.ring), func(i int) bool { return b.ring[i].hash >= h })
+	if i == len(b.ring) {
+		i = 0
+	}
+	return b.ring[i].instance
+}
+
+func (b *consistentHashBalancer) Done(instance string, latency time.Duration) {}
@@ -0,0 +1,163 @@
+package balancer
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/discovery"
+)
+
+const defaultDecay = 10000
+
+// outstanding counts the requests sent to every instance
+// that are not over yet
+type outstanding struct {
+	mutex    sync.Mutex
+	requests map[string]int
+}
+
+func newOutstanding() *outstanding {
+	return &outstanding{requests: make(map[string]int)}
+}
+
+func (o *outstanding) Done(instance string, latency time.Duration) {
+	o.mutex.Lock()
+	if o.requests[instance] > 0 {
+		o.requests[instance]--
+	}
+	o.mutex.Unlock()
+}
+
+// leastOutstandingBalancer chooses the instance with the fewest
+// outstanding requests, breaking ties at random
+type leastOutstandingBalancer struct {
+	*outstanding
+}
+
+func (b *leastOutstandingBalancer) Choose(instances []discovery.InstanceInfo, requestID string) string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	chosen, ties := "", 0
+	for _, instance := range instances {
+		address := instance.Address
+		switch {
+		case chosen == "" || b.requests[address] < b.requests[chosen]:
+			chosen, ties = address, 1
+		case b.requests[address] == b.requests[chosen]:
+			ties++
+			if rand.Intn(ties) == 0 {
+				chosen = address
+			}
+		}
+	}
+	b.requests[chosen]++
+
+	return chosen
+}
+
+// powerOfTwoBalancer picks two random instances and
+// chooses the one with fewer outstanding requests
+type powerOfTwoBalancer struct {
+	*outstanding
+}
+
+func (b *powerOfTwoBalancer) Choose(instances []discovery.InstanceInfo, requestID string) string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	first := rand.Intn(len(instances))
+	chosen := instances[first].Address
+	if len(instances) > 1 {
+		i := rand.Intn(len(instances) - 1)
+		if i == first {
+			i = len(instances) - 1
+		}
+		if b.requests[instances[i].Address] < b.requests[chosen] {
+			chosen = instances[i].Address
+		}
+	}
+	b.requests[chosen]++
+
+	return chosen
+}
+
+// ewmaBalancer chooses the instance with the lowest expected latency:
+// the moving average of its latencies, decaying with time, multiplied
+// by its outstanding requests plus one. The instances never used have
+// no latency, so every instance is tried at first.
+type ewmaBalancer struct {
+	decay     time.Duration
+	mutex     sync.Mutex
+	instances map[string]*ewmaStats
+}
+
+type ewmaStats struct {
+	latency     float64
+	updated     time.Time
+	outstanding int
+}
+
+func newEWMABalancer(decay time.Duration) *ewmaBalancer {
+	return &ewmaBalancer{
+		decay:     decay,
+		instances: make(map[string]*ewmaStats),
+	}
+}
+
+func (b *ewmaBalancer) stats(instance string) *ewmaStats {
+	s, ok := b.instances[instance]
+	if !ok {
+		s = &ewmaStats{}
+		b.instances[instance] = s
+	}
+	return s
+}
+
+func (b *ewmaBalancer) Choose(instances []discovery.InstanceInfo, requestID string) string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	chosen, best, ties := "", 0.0, 0
+	for _, instance := range instances {
+		s := b.stats(instance.Address)
+		cost := s.latency * float64(s.outstanding+1)
+		switch {
+		case chosen == "" || cost < best:
+			chosen, best, ties = instance.Address, cost, 1
+		case cost == best:
+			ties++
+			if rand.Intn(ties) == 0 {
+				chosen = instance.Address
+			}
+		}
+	}
+	b.stats(chosen).outstanding++
+
+	return chosen
+}
+
+func (b *ewmaBalancer) Done(instance string, latency time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s := b.stats(instance)
+	if s.outstanding > 0 {
+		s.outstanding--
+	}
+	if latency <= 0 {
+		return
+	}
+
+	now := time.Now()
+	sample := latency.Seconds() * 1000
+	if s.updated.IsZero() {
+		s.latency = sample
+	} else {
+		w := math.Exp(-float64(now.Sub(s.updated)) / float64(b.decay))
+		s.latency = s.latency*w + sample*(1-w)
+	}
+	s.updated = now
+}
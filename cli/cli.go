@@ -3,8 +3,12 @@ package cli
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/github.com/codegangsta/cli"
+
+	"github.com/elleFlorio/mu-sim/discovery"
+	"github.com/elleFlorio/mu-sim/tracing"
 )
 
 func Run() {
@@ -23,12 +27,224 @@ func Run() {
 			Name:   "start",
 			Usage:  "Start the a service",
 			Action: start,
+			Flags: append(infrastructureFlags(),
+				cli.StringFlag{
+					Name:  "port, p",
+					Value: "",
+					Usage: fmt.Sprintf("port of the service"),
+				},
+				cli.StringFlag{
+					Name:  "workload, w",
+					Value: "medium",
+					Usage: fmt.Sprintf("workload (options: none, low, medium, heavy or a distribution " +
+						"with its parameters, e.g. 'lognormal:mu=3,sigma=0.5'). Default is 'medium'"),
+				},
+				cli.Float64Flag{
+					Name:  "blocking",
+					Value: 0,
+					Usage: fmt.Sprintf("fraction of the execution time spent blocked instead of using the CPU. Default is 0"),
+				},
+				cli.IntFlag{
+					Name:  "workers, k",
+					Value: 0,
+					Usage: fmt.Sprintf("maximum number of requests computed at the same time. Default is 0 (unlimited)"),
+				},
+				cli.IntFlag{
+					Name:  "queue-length, q",
+					Value: 0,
+					Usage: fmt.Sprintf("maximum number of requests waiting for a worker. Default is 0 (unlimited)"),
+				},
+				cli.StringFlag{
+					Name:  "admission",
+					Value: "reject",
+					Usage: fmt.Sprintf("admission policy when the service is overloaded (options: reject, drop-oldest, lifo, codel). Default is 'reject'"),
+				},
+				cli.DurationFlag{
+					Name:  "codel-target",
+					Value: time.Duration(5) * time.Millisecond,
+					Usage: fmt.Sprintf("maximum queue time of the requests when the queue is congested, with the codel policy. Default is 5ms"),
+				},
+				cli.DurationFlag{
+					Name:  "codel-interval",
+					Value: time.Duration(100) * time.Millisecond,
+					Usage: fmt.Sprintf("maximum queue time of the requests, with the codel policy. Default is 100ms"),
+				},
+				cli.DurationFlag{
+					Name:  "timeout",
+					Value: time.Duration(30) * time.Second,
+					Usage: fmt.Sprintf("maximum time to wait for the destinations to respond. Default is 30s"),
+				},
+				cli.DurationFlag{
+					Name:  "deadline",
+					Value: 0,
+					Usage: fmt.Sprintf("end-to-end deadline of the new requests, propagated to the destinations. Default is none"),
+				},
+				cli.IntFlag{
+					Name:  "retries",
+					Value: 0,
+					Usage: fmt.Sprintf("maximum number of retries of a failed dispatch to a destination. Default is 0"),
+				},
+				cli.DurationFlag{
+					Name:  "retry-backoff",
+					Value: time.Duration(50) * time.Millisecond,
+					Usage: fmt.Sprintf("base of the exponential backoff between retries. Default is 50ms"),
+				},
+				cli.DurationFlag{
+					Name:  "retry-max-backoff",
+					Value: time.Duration(1) * time.Second,
+					Usage: fmt.Sprintf("maximum backoff between retries. Default is 1s"),
+				},
+				cli.IntFlag{
+					Name:  "breaker-threshold",
+					Value: 0,
+					Usage: fmt.Sprintf("consecutive failures of a destination that open its circuit breaker. Default is 0 (disabled)"),
+				},
+				cli.DurationFlag{
+					Name:  "breaker-cooldown",
+					Value: time.Duration(5) * time.Second,
+					Usage: fmt.Sprintf("time an open circuit breaker waits before letting a trial request through. Default is 5s"),
+				},
+				cli.Float64Flag{
+					Name:  "fault-error-rate",
+					Value: 0,
+					Usage: fmt.Sprintf("probability of answering a request with 'error'. Default is 0"),
+				},
+				cli.DurationFlag{
+					Name:  "fault-latency",
+					Value: 0,
+					Usage: fmt.Sprintf("latency added to every response. Default is 0"),
+				},
+				cli.Float64Flag{
+					Name:  "fault-drop-rate",
+					Value: 0,
+					Usage: fmt.Sprintf("probability of silently dropping a request. Default is 0"),
+				},
+				cli.IntFlag{
+					Name:  "fault-crash-after",
+					Value: 0,
+					Usage: fmt.Sprintf("number of requests after which the service crashes. Default is 0 (never)"),
+				},
+				cli.StringFlag{
+					Name:  "memory",
+					Value: "",
+					Usage: fmt.Sprintf("memory allocated and held by every request (e.g. 64MB). Default is none"),
+				},
+				cli.Float64Flag{
+					Name:  "memory-leak",
+					Value: 0,
+					Usage: fmt.Sprintf("fraction of the memory of every request that is never released. Default is 0"),
+				},
+				cli.StringFlag{
+					Name:  "disk",
+					Value: "",
+					Usage: fmt.Sprintf("bytes written to and read from disk by every request (e.g. 1MB). Default is none"),
+				},
+				cli.StringFlag{
+					Name:  "disk-dir",
+					Value: "",
+					Usage: fmt.Sprintf("scratch directory of the disk operations. Default is the system temporary directory"),
+				},
+				cli.StringFlag{
+					Name:  "disk-block",
+					Value: "4KB",
+					Usage: fmt.Sprintf("block size of the disk operations. Default is '4KB'"),
+				},
+				cli.StringFlag{
+					Name:  "disk-sync",
+					Value: "none",
+					Usage: fmt.Sprintf("when written data is synced to disk (options: none, end, block). Default is 'none'"),
+				},
+				cli.StringFlag{
+					Name:  "disk-pattern",
+					Value: "sequential",
+					Usage: fmt.Sprintf("access pattern of the disk operations (options: sequential, random). Default is 'sequential'"),
+				},
+				cli.StringSliceFlag{
+					Name:  "destination, d",
+					Value: &cli.StringSlice{},
+					Usage: fmt.Sprintf("destination of request messages, as service[:probability][:xcalls] " +
+						"(e.g. cache:0.8 or database:x3). Can be used several times to specify multiple destinations"),
+				},
+				cli.StringFlag{
+					Name:  "fanout",
+					Value: "parallel-all",
+					Usage: fmt.Sprintf("how the requests are sent to the destinations (options: parallel-all, sequential, random-one, weighted-one, quorum, first-response). Default is 'parallel-all'"),
+				},
+				cli.IntFlag{
+					Name:  "quorum",
+					Value: 0,
+					Usage: fmt.Sprintf("successful responses that complete a request, with the quorum fan-out. Default is the majority of the destinations"),
+				},
+				cli.StringFlag{
+					Name:  "weights",
+					Value: "",
+					Usage: fmt.Sprintf("comma separated weights of the destinations, in the same order, with the weighted-one fan-out. Default is the same weight for all"),
+				},
+				cli.StringSliceFlag{
+					Name:  "balancer",
+					Value: &cli.StringSlice{},
+					Usage: fmt.Sprintf("load balancing strategy (options: random, round-robin, weighted, least-outstanding, p2c, consistent-hash, ewma). " +
+						"Use service=strategy to set the strategy of a single destination. Can be used several times. Default is 'random'"),
+				},
+				cli.StringFlag{
+					Name:  "zone",
+					Value: "",
+					Usage: fmt.Sprintf("zone (or rack) of the instance, published in its registration"),
+				},
+				cli.StringFlag{
+					Name:  "version",
+					Value: "",
+					Usage: fmt.Sprintf("version tag of the instance, published in its registration"),
+				},
+				cli.Float64Flag{
+					Name:  "weight",
+					Value: 0,
+					Usage: fmt.Sprintf("weight of the instance with the weighted balancer, published in its registration. Default is 0, no weight (the balancer weighs it 1)"),
+				},
+				cli.BoolFlag{
+					Name:  "zone-affinity",
+					Usage: fmt.Sprintf("send the requests to the instances in the same zone, if there are any"),
+				},
+			),
+		},
+		{
+			Name:   "deploy",
+			Usage:  "Start and supervise all the services described in a topology file",
+			Action: deployTopology,
+			Flags: append(infrastructureFlags(),
+				cli.BoolFlag{
+					Name:  "no-restart",
+					Usage: fmt.Sprintf("do not restart the instances that exit unexpectedly"),
+				},
+				cli.DurationFlag{
+					Name:  "status-interval, s",
+					Value: time.Duration(30) * time.Second,
+					Usage: fmt.Sprintf("interval between status reports. Use 0 to disable them. Default is 30s"),
+				},
+				cli.DurationFlag{
+					Name:  "grace, g",
+					Value: time.Duration(60) * time.Second,
+					Usage: fmt.Sprintf("time to wait for the services to shut down before killing them. Default is 60s"),
+				},
+			),
+		},
+		{
+			Name:   "registry",
+			Usage:  "Serve a registry where the services find each other, instead of etcd",
+			Action: registry,
 			Flags: []cli.Flag{
 				cli.StringFlag{
-					Name:   "etcdserver, e",
-					Usage:  fmt.Sprintf("url of etcd server"),
-					EnvVar: "ETCD_ADDR",
+					Name:  "port, p",
+					Value: "8400",
+					Usage: fmt.Sprintf("port of the registry. Default is 8400"),
 				},
+			},
+		},
+		{
+			Name:   "simulate",
+			Usage:  "Run all the services described in a topology file in a single process",
+			Action: simulate,
+			Flags: append(append(metricFlags(), traceFlags()...),
 				cli.StringFlag{
 					Name:   "ipaddress, a",
 					Value:  "",
@@ -36,44 +252,299 @@ func Run() {
 					EnvVar: "HostIP",
 				},
 				cli.StringFlag{
-					Name:   "influxdb, m",
-					Usage:  fmt.Sprintf("url of influxdb"),
-					EnvVar: "INFLUX_ADDR",
+					Name:  "port, p",
+					Value: "",
+					Usage: fmt.Sprintf("port of the gateway to the entry service"),
 				},
 				cli.StringFlag{
-					Name:   "db-user, dbu",
-					Usage:  fmt.Sprintf("influxdb user username"),
-					EnvVar: "INFLUX_USER",
+					Name:  "entry, n",
+					Value: "",
+					Usage: fmt.Sprintf("entry service of the graph. Default is the first service of the topology"),
 				},
+				cli.BoolFlag{
+					Name:  "quiet, q",
+					Usage: fmt.Sprintf("do not log the activity of the services"),
+				},
+			),
+		},
+		{
+			Name:   "load",
+			Usage:  "Send requests to a service following an open-loop arrival pattern or from closed-loop virtual users",
+			Action: load,
+			Flags: append(loadFlags(),
 				cli.StringFlag{
-					Name:   "db-pwd, dbp",
-					Usage:  fmt.Sprintf("influxdb user password"),
-					EnvVar: "INFLUX_PWD",
+					Name:  "pattern",
+					Value: "poisson",
+					Usage: fmt.Sprintf("arrival pattern (options: poisson, constant, step, ramp). Default is 'poisson'"),
+				},
+				cli.Float64Flag{
+					Name:  "rate, r",
+					Value: 10,
+					Usage: fmt.Sprintf("requests per second (initial rate for step and ramp). Default is 10"),
+				},
+				cli.Float64Flag{
+					Name:  "end-rate",
+					Value: 0,
+					Usage: fmt.Sprintf("final requests per second of step and ramp patterns, required by them"),
+				},
+				cli.IntFlag{
+					Name:  "steps",
+					Value: 5,
+					Usage: fmt.Sprintf("number of steps of the step pattern. Default is 5"),
+				},
+				cli.IntFlag{
+					Name:  "users",
+					Value: 0,
+					Usage: fmt.Sprintf("number of closed-loop virtual users. If set, the arrival pattern is ignored"),
 				},
 				cli.StringFlag{
-					Name:  "db-name, db",
-					Value: "muSimDB",
-					Usage: fmt.Sprintf("influxdb database name. Default is 'testAppDB'"),
+					Name:  "think",
+					Value: "exponential:mean=1000",
+					Usage: fmt.Sprintf("think time of the virtual users, as a workload distribution. Default is 'exponential:mean=1000'"),
 				},
+				cli.DurationFlag{
+					Name:  "duration",
+					Value: time.Duration(1) * time.Minute,
+					Usage: fmt.Sprintf("duration of the test. Default is 1m"),
+				},
+			),
+		},
+		{
+			Name:   "replay",
+			Usage:  "Replay the requests of a trace file against a service",
+			Action: replay,
+			Flags: append(loadFlags(),
+				cli.Float64Flag{
+					Name:  "speedup, x",
+					Value: 1,
+					Usage: fmt.Sprintf("speed-up factor of the replay (2 halves the inter-arrival times). Default is 1"),
+				},
+			),
+		},
+		{
+			Name:   "ctl",
+			Usage:  "Show or change the workload, destinations and faults of running services",
+			Action: ctl,
+			Flags: append(discoveryFlags(),
 				cli.StringFlag{
-					Name:  "port, p",
+					Name:  "target, t",
 					Value: "",
-					Usage: fmt.Sprintf("port of the service"),
+					Usage: fmt.Sprintf("service whose instances are configured"),
+				},
+				cli.StringFlag{
+					Name:  "url, u",
+					Value: "",
+					Usage: fmt.Sprintf("address of the instance to configure, instead of discovering the instances of the target"),
 				},
 				cli.StringFlag{
 					Name:  "workload, w",
-					Value: "medium",
-					Usage: fmt.Sprintf("workload (options: none, low, medium, heavy). Default is 'medium'"),
+					Value: "",
+					Usage: fmt.Sprintf("new workload of the service"),
+				},
+				cli.Float64Flag{
+					Name:  "blocking",
+					Value: 0,
+					Usage: fmt.Sprintf("new fraction of the execution time spent blocked"),
 				},
 				cli.StringSliceFlag{
 					Name:  "destination, d",
 					Value: &cli.StringSlice{},
-					Usage: fmt.Sprintf("destination of request messages. Can be used " +
-						"several times to specify multiple destinations"),
+					Usage: fmt.Sprintf("new destinations of the service. Can be used several times"),
 				},
-			},
+				cli.BoolFlag{
+					Name:  "no-destinations",
+					Usage: fmt.Sprintf("remove all the destinations of the service"),
+				},
+				cli.StringFlag{
+					Name:  "fanout",
+					Value: "",
+					Usage: fmt.Sprintf("new fan-out mode of the service"),
+				},
+				cli.IntFlag{
+					Name:  "quorum",
+					Value: 0,
+					Usage: fmt.Sprintf("new quorum of the service (0 is the majority of the destinations)"),
+				},
+				cli.StringFlag{
+					Name:  "weights",
+					Value: "",
+					Usage: fmt.Sprintf("new comma separated weights of the destinations"),
+				},
+				cli.Float64Flag{
+					Name:  "fault-error-rate",
+					Value: 0,
+					Usage: fmt.Sprintf("new probability of answering a request with 'error'"),
+				},
+				cli.DurationFlag{
+					Name:  "fault-latency",
+					Value: 0,
+					Usage: fmt.Sprintf("new latency added to every response"),
+				},
+				cli.Float64Flag{
+					Name:  "fault-drop-rate",
+					Value: 0,
+					Usage: fmt.Sprintf("new probability of silently dropping a request"),
+				},
+				cli.IntFlag{
+					Name:  "fault-crash-after",
+					Value: 0,
+					Usage: fmt.Sprintf("number of further requests after which the service crashes"),
+				},
+			),
 		},
 	}
 
 	app.Run(os.Args)
 }
+
+// Flags shared by every command that starts services
+func infrastructureFlags() []cli.Flag {
+	flags := append(discoveryFlags(),
+		cli.StringFlag{
+			Name:   "ipaddress, a",
+			Value:  "",
+			Usage:  fmt.Sprintf("Ip address of the host"),
+			EnvVar: "HostIP",
+		},
+	)
+	return append(flags, append(metricFlags(), traceFlags()...)...)
+}
+
+// Flags needed to find the instances of the services
+func discoveryFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "etcdserver, e",
+			Usage:  fmt.Sprintf("url of etcd server"),
+			EnvVar: "ETCD_ADDR",
+		},
+		cli.StringFlag{
+			Name:   "registry-url",
+			Usage:  fmt.Sprintf("url of the registry started with the registry command"),
+			EnvVar: "REGISTRY_ADDR",
+		},
+		cli.StringFlag{
+			Name:   "discovery",
+			Usage:  fmt.Sprintf("where the instances of the services are found (options: etcd, registry, file, dns, static). Default is 'static' if there are peers, otherwise 'registry' or 'etcd' if their url is set"),
+			EnvVar: "DISCOVERY",
+		},
+		cli.DurationFlag{
+			Name:  "discovery-max-stale",
+			Value: discovery.DefaultMaxStale,
+			Usage: fmt.Sprintf("longest time the cached instances of a service are used without being confirmed by etcd (or the registry, or DNS). Use 0 to disable the cache. Default is 10s"),
+		},
+		cli.StringFlag{
+			Name:   "discovery-file",
+			Usage:  fmt.Sprintf("YAML or JSON file mapping every service to the urls of its instances, with the file discovery"),
+			EnvVar: "DISCOVERY_FILE",
+		},
+		cli.StringFlag{
+			Name:   "dns-domain",
+			Usage:  fmt.Sprintf("domain of the SRV records _<service>._tcp.<domain>, with the dns discovery"),
+			EnvVar: "DNS_DOMAIN",
+		},
+		cli.StringSliceFlag{
+			Name:  "peer",
+			Value: &cli.StringSlice{},
+			Usage: fmt.Sprintf("instance of a service, as name=url, with the static discovery. Can be used several times"),
+		},
+	}
+}
+
+func discoveryConfig(c *cli.Context) discovery.Config {
+	return discovery.Config{
+		Provider:    c.String("discovery"),
+		EtcdAddress: c.String("etcdserver"),
+		RegistryURL: c.String("registry-url"),
+		MaxStale:    c.Duration("discovery-max-stale"),
+		File:        c.String("discovery-file"),
+		Domain:      c.String("dns-domain"),
+		Peers:       c.StringSlice("peer"),
+	}
+}
+
+// Flags needed to send the metrics to influxdb
+func metricFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "influxdb, m",
+			Usage:  fmt.Sprintf("url of influxdb"),
+			EnvVar: "INFLUX_ADDR",
+		},
+		cli.StringFlag{
+			Name:   "db-user, dbu",
+			Usage:  fmt.Sprintf("influxdb user username"),
+			EnvVar: "INFLUX_USER",
+		},
+		cli.StringFlag{
+			Name:   "db-pwd, dbp",
+			Usage:  fmt.Sprintf("influxdb user password"),
+			EnvVar: "INFLUX_PWD",
+		},
+		cli.StringFlag{
+			Name:  "db-name, db",
+			Value: "muSimDB",
+			Usage: fmt.Sprintf("influxdb database name. Default is 'testAppDB'"),
+		},
+	}
+}
+
+// Flags needed to export the spans of the requests
+func traceFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "trace-file",
+			Usage:  fmt.Sprintf("file where the spans are appended, one JSON span per line"),
+			EnvVar: "TRACE_FILE",
+		},
+		cli.StringFlag{
+			Name:   "trace-collector",
+			Usage:  fmt.Sprintf("url of a collector accepting Zipkin v2 spans (e.g. http://localhost:9411/api/v2/spans)"),
+			EnvVar: "TRACE_COLLECTOR",
+		},
+	}
+}
+
+func traceConfig(c *cli.Context) tracing.Config {
+	return tracing.Config{
+		File:      c.String("trace-file"),
+		Collector: c.String("trace-collector"),
+	}
+}
+
+// Flags shared by the load generators
+func loadFlags() []cli.Flag {
+	return append(discoveryFlags(),
+		cli.StringFlag{
+			Name:   "ipaddress, a",
+			Value:  "",
+			Usage:  fmt.Sprintf("Ip address of the host, where the responses are received"),
+			EnvVar: "HostIP",
+		},
+		cli.StringFlag{
+			Name:  "port, p",
+			Value: "",
+			Usage: fmt.Sprintf("port where the responses are received"),
+		},
+		cli.StringFlag{
+			Name:  "target, t",
+			Value: "",
+			Usage: fmt.Sprintf("service that receives the requests"),
+		},
+		cli.StringFlag{
+			Name:  "url, u",
+			Value: "",
+			Usage: fmt.Sprintf("address of the service that receives the requests, instead of discovering the target"),
+		},
+		cli.StringFlag{
+			Name:  "service, s",
+			Value: "",
+			Usage: fmt.Sprintf("service the target should send the requests to"),
+		},
+		cli.DurationFlag{
+			Name:  "wait",
+			Value: time.Duration(30) * time.Second,
+			Usage: fmt.Sprintf("maximum time to wait for the pending responses at the end of the test. Default is 30s"),
+		},
+	)
+}
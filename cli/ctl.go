@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/github.com/codegangsta/cli"
+
+	"github.com/elleFlorio/mu-sim/app"
+	"github.com/elleFlorio/mu-sim/discovery"
+)
+
+const adminConfigPath = "/admin/config"
+
+// ctl shows or changes the live configuration of a service instance,
+// or of all the instances of a service
+func ctl(c *cli.Context) {
+	instances := ctlInstances(c)
+
+	failed := false
+	for _, instance := range instances {
+		live, err := ctlInstance(c, instance)
+		if err != nil {
+			log.Printf("Cannot configure %s: %s\n", instance, err.Error())
+			failed = true
+			continue
+		}
+		data, _ := json.Marshal(live)
+		fmt.Printf("%s %s\n", instance, data)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func ctlInstances(c *cli.Context) []string {
+	if url := c.String("url"); url != "" {
+		return []string{url}
+	}
+
+	target := c.String("target")
+	if target == "" {
+		log.Fatalln("Cannot configure: target service is missing")
+	}
+	cfg := discoveryConfig(c)
+	registry, err := discovery.NewRegistry(cfg)
+	if err != nil {
+		log.Fatalln("Cannot find the instances through the", cfg.Describe()+":", err)
+	}
+	instances, err := registry.GetAvailableInstances(target)
+	if err != nil {
+		log.Fatalln("Cannot find the instances of", target)
+	}
+
+	return discovery.Addresses(instances)
+}
+
+func ctlInstance(c *cli.Context, instance string) (app.LiveConfig, error) {
+	live, err := getLiveConfig(instance)
+	if err != nil {
+		return app.LiveConfig{}, err
+	}
+
+	u, changed := ctlUpdate(c, live)
+	if !changed {
+		return live, nil
+	}
+
+	return putLiveConfig(instance, u)
+}
+
+// ctlUpdate builds the update from the flags that are set. The faults
+// not set are kept, as the update replaces all of them.
+func ctlUpdate(c *cli.Context, live app.LiveConfig) (app.ConfigUpdate, bool) {
+	u := app.ConfigUpdate{}
+	changed := false
+
+	if c.IsSet("workload") {
+		workload := c.String("workload")
+		u.Workload = &workload
+		changed = true
+	}
+	if c.IsSet("blocking") {
+		blocking := c.Float64("blocking")
+		u.Blocking = &blocking
+		changed = true
+	}
+	if destinations := c.StringSlice("destination"); len(destinations) > 0 || c.Bool("no-destinations") {
+		if c.Bool("no-destinations") {
+			destinations = []string{}
+		}
+		u.Destinations = &destinations
+		changed = true
+	}
+	if c.IsSet("fanout") {
+		fanOut := c.String("fanout")
+		u.FanOut = &fanOut
+		changed = true
+	}
+	if c.IsSet("quorum") {
+		quorum := c.Int("quorum")
+		u.Quorum = &quorum
+		changed = true
+	}
+	if c.IsSet("weights") {
+		weights := parseWeights(c.String("weights"))
+		u.Weights = &weights
+		changed = true
+	}
+
+	faults := live.Faults
+	faultsChanged := false
+	if c.IsSet("fault-error-rate") {
+		faults.ErrorRate = c.Float64("fault-error-rate")
+		faultsChanged = true
+	}
+	if c.IsSet("fault-latency") {
+		faults.LatencyMs = c.Duration("fault-latency").Seconds() * 1000
+		faultsChanged = true
+	}
+	if c.IsSet("fault-drop-rate") {
+		faults.DropRate = c.Float64("fault-drop-rate")
+		faultsChanged = true
+	}
+	if c.IsSet("fault-crash-after") {
+		faults.CrashAfter = c.Int("fault-crash-after")
+		faultsChanged = true
+	}
+	if faultsChanged {
+		u.Faults = &faults
+		changed = true
+	}
+
+	return u, changed
+}
+
+func getLiveConfig(instance string) (app.LiveConfig, error) {
+	resp, err := http.Get(instance + adminConfigPath)
+	if err != nil {
+		return app.LiveConfig{}, err
+	}
+
+	return readLiveConfig(resp)
+}
+
+func putLiveConfig(instance string, u app.ConfigUpdate) (app.LiveConfig, error) {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return app.LiveConfig{}, err
+	}
+
+	req, err := http.NewRequest("PUT", instance+adminConfigPath, bytes.NewBuffer(data))
+	if err != nil {
+		return app.LiveConfig{}, err
+	}
+	req.Header.Add("Content-type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return app.LiveConfig{}, err
+	}
+
+	return readLiveConfig(resp)
+}
+
+func readLiveConfig(resp *http.Response) (app.LiveConfig, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return app.LiveConfig{}, fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var live app.LiveConfig
+	err := json.NewDecoder(resp.Body).Decode(&live)
+	return live, err
+}
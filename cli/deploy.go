@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"log"
+	"os"
+
+	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/github.com/codegangsta/cli"
+
+	"github.com/elleFlorio/mu-sim/deploy"
+	"github.com/elleFlorio/mu-sim/topology"
+)
+
+// Infrastructure and tracing flags forwarded to every started service
+var forwardedFlags = []string{
+	"etcdserver",
+	"registry-url",
+	"discovery",
+	"discovery-max-stale",
+	"discovery-file",
+	"dns-domain",
+	"ipaddress",
+	"influxdb",
+	"db-user",
+	"db-pwd",
+	"db-name",
+	"trace-file",
+	"trace-collector",
+}
+
+func deployTopology(c *cli.Context) {
+	if !c.Args().Present() {
+		log.Fatalln("Cannot deploy: topology file is missing")
+	}
+
+	topo, err := topology.Load(c.Args().First())
+	if err != nil {
+		log.Fatalln("Cannot read topology file:", err)
+	}
+
+	args := []string{}
+	for _, flag := range forwardedFlags {
+		if value := c.String(flag); value != "" {
+			args = append(args, "--"+flag, value)
+		}
+	}
+	for _, peer := range c.StringSlice("peer") {
+		args = append(args, "--peer", peer)
+	}
+
+	opts := deploy.Options{
+		Executable:     os.Args[0],
+		Args:           args,
+		Restart:        !c.Bool("no-restart"),
+		StatusInterval: c.Duration("status-interval"),
+		Grace:          c.Duration("grace"),
+	}
+
+	deploy.Run(topo, opts)
+}
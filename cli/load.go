@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"log"
+	"os"
+
+	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/github.com/codegangsta/cli"
+
+	"github.com/elleFlorio/mu-sim/discovery"
+	"github.com/elleFlorio/mu-sim/loadgen"
+	"github.com/elleFlorio/mu-sim/network"
+	"github.com/elleFlorio/mu-sim/worker"
+)
+
+func load(c *cli.Context) {
+	think, err := worker.ParseWorkload(c.String("think"))
+	if err != nil {
+		log.Fatalln("Invalid think time:", err)
+	}
+
+	opts := loadgen.Options{
+		Target:   c.String("target"),
+		Users:    c.Int("users"),
+		Think:    think,
+		Service:  c.String("service"),
+		Pattern:  c.String("pattern"),
+		Rate:     c.Float64("rate"),
+		EndRate:  c.Float64("end-rate"),
+		Steps:    c.Int("steps"),
+		Duration: c.Duration("duration"),
+		Wait:     c.Duration("wait"),
+	}
+
+	resolver, address := loadResolver(c)
+	if opts.Target == "" {
+		opts.Target = c.String("url")
+	}
+	g := loadgen.New(opts, address, network.NewHTTPTransport(), resolver)
+	var report *loadgen.Report
+	if opts.Users > 0 {
+		report, err = g.RunClosed()
+	} else {
+		report, err = g.Run()
+	}
+	if err != nil {
+		log.Fatalln("Cannot run load test:", err)
+	}
+	report.Print(os.Stdout)
+}
+
+// loadResolver returns how to find the target of the load and the
+// address where the responses should be sent
+func loadResolver(c *cli.Context) (loadgen.Resolver, string) {
+	port := c.String("port")
+	if port != "" {
+		port = ":" + port
+	}
+	address := network.GenerateAddress(c.String("ipaddress"), port)
+
+	if url := c.String("url"); url != "" {
+		return loadgen.StaticResolver(url), address
+	}
+
+	if c.String("target") == "" {
+		log.Fatalln("Cannot run load test: target service is missing")
+	}
+	cfg := discoveryConfig(c)
+	registry, err := discovery.NewRegistry(cfg)
+	if err != nil {
+		log.Fatalln("Cannot find the instances through the", cfg.Describe()+":", err)
+	}
+
+	return registry, address
+}
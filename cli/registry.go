@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/github.com/codegangsta/cli"
+
+	"github.com/elleFlorio/mu-sim/discovery"
+)
+
+func registry(c *cli.Context) {
+	address := ":" + c.String("port")
+
+	server := discovery.NewRegistryServer()
+	if err := server.Start(address); err != nil {
+		log.Fatalln("Cannot start registry:", err)
+	}
+	log.Println("Registry listening at", address)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	<-sigs
+	log.Println("Received shutdown signal")
+	server.Stop()
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"log"
+	"os"
+
+	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/github.com/codegangsta/cli"
+
+	"github.com/elleFlorio/mu-sim/loadgen"
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+func replay(c *cli.Context) {
+	if !c.Args().Present() {
+		log.Fatalln("Cannot replay: trace file is missing")
+	}
+
+	trace, err := os.Open(c.Args().First())
+	if err != nil {
+		log.Fatalln("Cannot open trace file:", err)
+	}
+	defer trace.Close()
+
+	opts := loadgen.Options{
+		Target:  c.String("target"),
+		Service: c.String("service"),
+		Wait:    c.Duration("wait"),
+	}
+
+	resolver, address := loadResolver(c)
+	if opts.Target == "" {
+		opts.Target = c.String("url")
+	}
+
+	g := loadgen.New(opts, address, network.NewHTTPTransport(), resolver)
+	report, err := g.Replay(trace, c.Float64("speedup"))
+	if err != nil {
+		log.Fatalln("Cannot replay trace:", err)
+	}
+	report.Print(os.Stdout)
+}
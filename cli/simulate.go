@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/github.com/codegangsta/cli"
+
+	"github.com/elleFlorio/mu-sim/metric"
+	"github.com/elleFlorio/mu-sim/network"
+	"github.com/elleFlorio/mu-sim/simulation"
+	"github.com/elleFlorio/mu-sim/topology"
+)
+
+func simulate(c *cli.Context) {
+	if !c.Args().Present() {
+		log.Fatalln("Cannot simulate: topology file is missing")
+	}
+
+	topo, err := topology.Load(c.Args().First())
+	if err != nil {
+		log.Fatalln("Cannot read topology file:", err)
+	}
+
+	entry := c.String("entry")
+	if entry == "" {
+		entry = topo.Services[0].Name
+	}
+	if _, ok := topo.Service(entry); !ok {
+		log.Fatalln("Entry service is not defined in topology:", entry)
+	}
+
+	port := c.String("port")
+	if port != "" {
+		port = ":" + port
+	}
+
+	opts := simulation.Options{
+		Influx: metric.InfluxConfig{
+			Address:  c.String("influxdb"),
+			DBname:   c.String("db-name"),
+			Username: c.String("db-user"),
+			Password: c.String("db-pwd"),
+		},
+		Tracing: traceConfig(c),
+		Quiet:   c.Bool("quiet"),
+	}
+
+	sim, err := simulation.New(topo, opts)
+	if err != nil {
+		log.Fatalln("Cannot create simulation:", err)
+	}
+	if err = sim.Start(); err != nil {
+		log.Fatalln("Cannot start simulation:", err)
+	}
+
+	gateway := simulation.NewGateway(sim, entry, network.GenerateAddress(c.String("ipaddress"), port))
+	if err = gateway.Start(); err != nil {
+		log.Fatalln("Cannot start gateway:", err)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	<-sigs
+	log.Println("Received shutdown signal")
+	sim.Stop()
+	gateway.Stop()
+}
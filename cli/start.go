@@ -3,6 +3,7 @@ package cli
 import (
 	"log"
 	"strconv"
+	"strings"
 
 	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/github.com/codegangsta/cli"
 
@@ -16,7 +17,6 @@ func start(c *cli.Context) {
 	}
 
 	name := c.Args().First()
-	etcdAddress := c.String("etcdserver")
 
 	influxAddress := c.String("influxdb")
 	influxDB := c.String("db-name")
@@ -39,17 +39,70 @@ func start(c *cli.Context) {
 	destinations := c.StringSlice("destination")
 
 	params := app.ServiceParams{
-		etcdAddress,
-		influxAddress,
-		influxDB,
-		influxUser,
-		influxPwd,
-		ip,
-		port,
-		name,
-		workload,
-		destinations,
+		Discovery:     discoveryConfig(c),
+		InfluxAddress: influxAddress,
+		InfluxDbName:  influxDB,
+		InfluxUser:    influxUser,
+		InfluxPwd:     influxPwd,
+		Tracing:       traceConfig(c),
+		Ip:            ip,
+		Port:          port,
+		Config: app.Config{
+			Name:             name,
+			Workload:         workload,
+			Destinations:     destinations,
+			FanOut:           c.String("fanout"),
+			Quorum:           c.Int("quorum"),
+			Weights:          parseWeights(c.String("weights")),
+			Balancers:        c.StringSlice("balancer"),
+			Zone:             c.String("zone"),
+			Version:          c.String("version"),
+			Weight:           c.Float64("weight"),
+			ZoneAffinity:     c.Bool("zone-affinity"),
+			Blocking:         c.Float64("blocking"),
+			Workers:          c.Int("workers"),
+			QueueLength:      c.Int("queue-length"),
+			Admission:        c.String("admission"),
+			CoDelTarget:      c.Duration("codel-target"),
+			CoDelInterval:    c.Duration("codel-interval"),
+			Timeout:          c.Duration("timeout"),
+			Deadline:         c.Duration("deadline"),
+			Retries:          c.Int("retries"),
+			RetryBackoff:     c.Duration("retry-backoff"),
+			RetryMaxBackoff:  c.Duration("retry-max-backoff"),
+			BreakerThreshold: c.Int("breaker-threshold"),
+			BreakerCooldown:  c.Duration("breaker-cooldown"),
+			FaultErrorRate:   c.Float64("fault-error-rate"),
+			FaultLatency:     c.Duration("fault-latency"),
+			FaultDropRate:    c.Float64("fault-drop-rate"),
+			FaultCrashAfter:  c.Int("fault-crash-after"),
+			Memory:           c.String("memory"),
+			MemoryLeak:       c.Float64("memory-leak"),
+			Disk:             c.String("disk"),
+			DiskDir:          c.String("disk-dir"),
+			DiskBlock:        c.String("disk-block"),
+			DiskSync:         c.String("disk-sync"),
+			DiskPattern:      c.String("disk-pattern"),
+		},
 	}
 
 	app.StartService(params)
 }
+
+// parseWeights reads a comma separated list of weights
+func parseWeights(spec string) []float64 {
+	if spec == "" {
+		return nil
+	}
+
+	weights := []float64{}
+	for _, w := range strings.Split(spec, ",") {
+		weight, err := strconv.ParseFloat(strings.TrimSpace(w), 64)
+		if err != nil {
+			log.Fatalln("Invalid weight:", w)
+		}
+		weights = append(weights, weight)
+	}
+
+	return weights
+}
@@ -0,0 +1,99 @@
+package deploy
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/topology"
+)
+
+// Options controls how the graph is deployed and supervised
+type Options struct {
+	Executable     string
+	Args           []string
+	Restart        bool
+	StatusInterval time.Duration
+	Grace          time.Duration
+}
+
+const restartDelay = time.Duration(1) * time.Second
+
+// Run starts a process for every replica of every service of the topology,
+// supervises them and tears the whole graph down when a shutdown signal
+// is received or when every process has exited.
+func Run(topo topology.Topology, opts Options) {
+	instances := []*instance{}
+	for _, s := range topo.Services {
+		for i := 0; i < s.Replicas; i++ {
+			instances = append(instances, newInstance(s, i, opts))
+		}
+	}
+
+	var wg sync.WaitGroup
+	ch_stop := make(chan struct{})
+	for _, inst := range instances {
+		wg.Add(1)
+		go func(inst *instance) {
+			defer wg.Done()
+			inst.supervise(ch_stop)
+		}(inst)
+	}
+
+	ch_exited := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(ch_exited)
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	var ch_status <-chan time.Time
+	if opts.StatusInterval > 0 {
+		ticker := time.NewTicker(opts.StatusInterval)
+		defer ticker.Stop()
+		ch_status = ticker.C
+	}
+
+	log.Printf("Deployed %d instances\n", len(instances))
+	for {
+		select {
+		case <-ch_status:
+			printStatus(instances)
+		case <-ch_exited:
+			log.Println("All the instances exited")
+			printStatus(instances)
+			return
+		case <-sigs:
+			log.Println("Received shutdown signal, tearing down the graph")
+			close(ch_stop)
+			teardown(instances, ch_exited, opts.Grace)
+			printStatus(instances)
+			return
+		}
+	}
+}
+
+func teardown(instances []*instance, ch_exited chan struct{}, grace time.Duration) {
+	for _, inst := range instances {
+		inst.signal(syscall.SIGTERM)
+	}
+
+	timeout := time.NewTimer(grace)
+	defer timeout.Stop()
+	select {
+	case <-ch_exited:
+		log.Println("Graph shut down")
+	case <-timeout.C:
+		log.Println("Grace period expired, killing the remaining instances")
+		for _, inst := range instances {
+			inst.signal(os.Kill)
+		}
+		<-ch_exited
+	}
+}
@@ -0,0 +1,261 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/topology"
+)
+
+type status string
+
+const (
+	statusStarting status = "starting"
+	statusRunning  status = "running"
+	statusCrashed  status = "crashed"
+	statusExited   status = "exited"
+	statusStopped  status = "stopped"
+)
+
+type instance struct {
+	id       string
+	args     []string
+	opts     Options
+	mutex    sync.Mutex
+	status   status
+	pid      int
+	restarts int
+	started  time.Time
+	lastErr  error
+	process  *os.Process
+}
+
+func newInstance(s topology.Service, replica int, opts Options) *instance {
+	args := []string{"start", s.Name, "-w", s.Workload}
+	for _, dest := range s.Destinations {
+		args = append(args, "-d", dest)
+	}
+	if s.FanOut != "" {
+		args = append(args, "--fanout", s.FanOut)
+	}
+	if s.Quorum > 0 {
+		args = append(args, "--quorum", strconv.Itoa(s.Quorum))
+	}
+	if len(s.Weights) > 0 {
+		weights := make([]string, len(s.Weights))
+		for i, w := range s.Weights {
+			weights[i] = strconv.FormatFloat(w, 'f', -1, 64)
+		}
+		args = append(args, "--weights", strings.Join(weights, ","))
+	}
+	for _, b := range s.Balancers {
+		args = append(args, "--balancer", b)
+	}
+	if s.Zone != "" {
+		args = append(args, "--zone", s.Zone)
+	}
+	if s.Version != "" {
+		args = append(args, "--version", s.Version)
+	}
+	if s.Weight > 0 {
+		args = append(args, "--weight", strconv.FormatFloat(s.Weight, 'f', -1, 64))
+	}
+	if s.ZoneAffinity {
+		args = append(args, "--zone-affinity")
+	}
+	if s.Blocking > 0 {
+		args = append(args, "--blocking", strconv.FormatFloat(s.Blocking, 'f', -1, 64))
+	}
+	if s.Workers > 0 {
+		args = append(args, "--workers", strconv.Itoa(s.Workers))
+	}
+	if s.QueueLength > 0 {
+		args = append(args, "--queue-length", strconv.Itoa(s.QueueLength))
+	}
+	if s.Admission != "" {
+		args = append(args, "--admission", s.Admission)
+	}
+	if s.CoDelTarget > 0 {
+		args = append(args, "--codel-target", s.CoDelTarget.String())
+	}
+	if s.CoDelInterval > 0 {
+		args = append(args, "--codel-interval", s.CoDelInterval.String())
+	}
+	if s.Timeout > 0 {
+		args = append(args, "--timeout", s.Timeout.String())
+	}
+	if s.Deadline > 0 {
+		args = append(args, "--deadline", s.Deadline.String())
+	}
+	if s.Retries > 0 {
+		args = append(args, "--retries", strconv.Itoa(s.Retries))
+	}
+	if s.RetryBackoff > 0 {
+		args = append(args, "--retry-backoff", s.RetryBackoff.String())
+	}
+	if s.RetryMaxBackoff > 0 {
+		args = append(args, "--retry-max-backoff", s.RetryMaxBackoff.String())
+	}
+	if s.BreakerThreshold > 0 {
+		args = append(args, "--breaker-threshold", strconv.Itoa(s.BreakerThreshold))
+	}
+	if s.BreakerCooldown > 0 {
+		args = append(args, "--breaker-cooldown", s.BreakerCooldown.String())
+	}
+	if s.FaultErrorRate > 0 {
+		args = append(args, "--fault-error-rate", strconv.FormatFloat(s.FaultErrorRate, 'f', -1, 64))
+	}
+	if s.FaultLatency > 0 {
+		args = append(args, "--fault-latency", s.FaultLatency.String())
+	}
+	if s.FaultDropRate > 0 {
+		args = append(args, "--fault-drop-rate", strconv.FormatFloat(s.FaultDropRate, 'f', -1, 64))
+	}
+	if s.FaultCrashAfter > 0 {
+		args = append(args, "--fault-crash-after", strconv.Itoa(s.FaultCrashAfter))
+	}
+	if s.Memory != "" {
+		args = append(args, "--memory", s.Memory)
+	}
+	if s.MemoryLeak > 0 {
+		args = append(args, "--memory-leak", strconv.FormatFloat(s.MemoryLeak, 'f', -1, 64))
+	}
+	for _, opt := range []struct{ flag, value string }{
+		{"--disk", s.Disk},
+		{"--disk-dir", s.DiskDir},
+		{"--disk-block", s.DiskBlock},
+		{"--disk-sync", s.DiskSync},
+		{"--disk-pattern", s.DiskPattern},
+	} {
+		if opt.value != "" {
+			args = append(args, opt.flag, opt.value)
+		}
+	}
+	if port := s.ReplicaPort(replica); port != 0 {
+		args = append(args, "-p", strconv.Itoa(port))
+	}
+	args = append(args, opts.Args...)
+
+	return &instance{
+		id:     s.Name + "#" + strconv.Itoa(replica),
+		args:   args,
+		opts:   opts,
+		status: statusStarting,
+	}
+}
+
+func (inst *instance) supervise(ch_stop chan struct{}) {
+	for {
+		err := inst.run()
+
+		select {
+		case <-ch_stop:
+			inst.setExited(statusStopped, err)
+			log.Printf("Instance %s stopped\n", inst.id)
+			return
+		default:
+		}
+
+		if !inst.opts.Restart {
+			inst.setExited(statusExited, err)
+			log.Printf("Instance %s exited: %v\n", inst.id, err)
+			return
+		}
+
+		inst.setExited(statusCrashed, err)
+		log.Printf("Instance %s exited: %v. Restarting...\n", inst.id, err)
+		select {
+		case <-ch_stop:
+			inst.setExited(statusStopped, err)
+			return
+		case <-time.After(restartDelay):
+			inst.mutex.Lock()
+			inst.restarts++
+			inst.mutex.Unlock()
+		}
+	}
+}
+
+func (inst *instance) run() error {
+	output := &prefixWriter{prefix: "[" + inst.id + "] "}
+	cmd := exec.Command(inst.opts.Executable, inst.args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	inst.mutex.Lock()
+	inst.process = cmd.Process
+	inst.pid = cmd.Process.Pid
+	inst.started = time.Now()
+	inst.status = statusRunning
+	inst.mutex.Unlock()
+	log.Printf("Started instance %s (pid %d)\n", inst.id, cmd.Process.Pid)
+
+	err := cmd.Wait()
+	output.Flush()
+	return err
+}
+
+func (inst *instance) setExited(s status, err error) {
+	inst.mutex.Lock()
+	inst.status = s
+	inst.process = nil
+	inst.lastErr = err
+	inst.mutex.Unlock()
+}
+
+func (inst *instance) signal(sig os.Signal) {
+	inst.mutex.Lock()
+	defer inst.mutex.Unlock()
+	if inst.process == nil {
+		return
+	}
+	if err := inst.process.Signal(sig); err != nil {
+		log.Printf("Cannot signal instance %s: %s\n", inst.id, err.Error())
+	}
+}
+
+// prefixWriter writes the output of an instance line by line,
+// prefixing every line with the ID of the instance
+type prefixWriter struct {
+	prefix string
+	mutex  sync.Mutex
+	buf    bytes.Buffer
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadBytes('\n')
+		if err != nil {
+			// Incomplete line: keep it for the next write
+			w.buf.Write(line)
+			break
+		}
+		fmt.Fprint(os.Stdout, w.prefix+string(line))
+	}
+
+	return len(p), nil
+}
+
+func (w *prefixWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.buf.Len() > 0 {
+		fmt.Fprintln(os.Stdout, w.prefix+w.buf.String())
+		w.buf.Reset()
+	}
+}
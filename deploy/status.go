@@ -0,0 +1,28 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func printStatus(instances []*instance) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "INSTANCE\tPID\tSTATUS\tRESTARTS\tUPTIME\tLAST ERROR")
+	for _, inst := range instances {
+		inst.mutex.Lock()
+		uptime := "-"
+		if inst.status == statusRunning {
+			uptime = (time.Since(inst.started) / time.Second * time.Second).String()
+		}
+		lastErr := "-"
+		if inst.lastErr != nil {
+			lastErr = inst.lastErr.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\t%s\n",
+			inst.id, inst.pid, inst.status, inst.restarts, uptime, lastErr)
+		inst.mutex.Unlock()
+	}
+	tw.Flush()
+}
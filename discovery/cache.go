@@ -0,0 +1,187 @@
+package discovery
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/github.com/coreos/etcd/client"
+	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/golang.org/x/net/context"
+)
+
+const (
+	DefaultMaxStale = time.Duration(10) * time.Second
+
+	fetchTimeout  = time.Duration(1) * time.Second
+	retryInterval = time.Duration(1) * time.Second
+)
+
+// instanceCache keeps the instances of the services looked up through
+// etcd. The first lookup of a service reads its instances and starts
+// watching mu-sim/<service>/, so the cache follows every registration,
+// keep alive and expiration. The instances are read again if nothing
+// confirmed them for maxStale (e.g. the watch is broken), and if etcd
+// cannot be reached the last known instances are used. The watches
+// last until the cache is stopped.
+type instanceCache struct {
+	kAPI     client.KeysAPI
+	maxStale time.Duration
+	mutex    sync.Mutex
+	services map[string]*cachedService
+	ctx      context.Context
+	stop     context.CancelFunc
+}
+
+type cachedService struct {
+	// Record of every instance, by key
+	instances map[string]InstanceInfo
+	synced    time.Time
+	nextFetch time.Time
+	watching  bool
+	fallback  bool
+}
+
+func newInstanceCache(kAPI client.KeysAPI, maxStale time.Duration) *instanceCache {
+	ctx, stop := context.WithCancel(context.Background())
+	return &instanceCache{
+		kAPI:     kAPI,
+		maxStale: maxStale,
+		services: make(map[string]*cachedService),
+		ctx:      ctx,
+		stop:     stop,
+	}
+}
+
+func serviceKey(service string) string {
+	return "mu-sim/" + service + "/"
+}
+
+func (c *instanceCache) lookup(service string) ([]InstanceInfo, error) {
+	now := time.Now()
+	c.mutex.Lock()
+	cs, known := c.services[service]
+	if known && (now.Sub(cs.synced) <= c.maxStale || now.Before(cs.nextFetch)) {
+		instances := cs.list()
+		c.mutex.Unlock()
+		return instances, nil
+	}
+	c.mutex.Unlock()
+
+	instances, index, err := c.fetch(service)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cs, known = c.services[service]
+	if err != nil {
+		if !known {
+			return nil, err
+		}
+		if !cs.fallback {
+			log.Printf("Cannot read the instances of %s, using the last known ones: %s\n", service, err)
+			cs.fallback = true
+		}
+		cs.nextFetch = time.Now().Add(retryInterval)
+		return cs.list(), nil
+	}
+
+	if !known {
+		cs = &cachedService{}
+		c.services[service] = cs
+	}
+	cs.replace(instances)
+	if !cs.watching && c.ctx.Err() == nil {
+		cs.watching = true
+		go c.watch(service, index)
+	}
+	return cs.list(), nil
+}
+
+// fetch reads the instances of the service, with the
+// etcd index to start watching from
+func (c *instanceCache) fetch(service string) (map[string]InstanceInfo, uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	instances := make(map[string]InstanceInfo)
+	resp, err := c.kAPI.Get(ctx, serviceKey(service), nil)
+	if err != nil {
+		if e, ok := err.(client.Error); ok && e.Code == client.ErrorCodeKeyNotFound {
+			// No instance registered yet
+			return instances, e.Index, nil
+		}
+		return nil, 0, err
+	}
+
+	for _, n := range resp.Node.Nodes {
+		instances[n.Key] = parseInstance(n.Value)
+	}
+	return instances, resp.Index, nil
+}
+
+// watch applies the changes of the instances of the service to the
+// cache. When the watch breaks the instances are read again and
+// the watch restarts from there, until the cache is stopped.
+func (c *instanceCache) watch(service string, index uint64) {
+	for {
+		w := c.kAPI.Watcher(serviceKey(service), &client.WatcherOptions{
+			AfterIndex: index,
+			Recursive:  true,
+		})
+		for {
+			resp, err := w.Next(c.ctx)
+			if err != nil {
+				break
+			}
+			index = resp.Node.ModifiedIndex
+			c.apply(service, resp)
+		}
+
+		for {
+			select {
+			case <-time.After(retryInterval):
+			case <-c.ctx.Done():
+				return
+			}
+			instances, i, err := c.fetch(service)
+			if err == nil {
+				c.mutex.Lock()
+				c.services[service].replace(instances)
+				c.mutex.Unlock()
+				index = i
+				break
+			}
+		}
+	}
+}
+
+func (c *instanceCache) apply(service string, resp *client.Response) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cs := c.services[service]
+	switch resp.Action {
+	case "set", "create", "update", "compareAndSwap":
+		cs.instances[resp.Node.Key] = parseInstance(resp.Node.Value)
+	case "delete", "expire", "compareAndDelete":
+		delete(cs.instances, resp.Node.Key)
+	}
+	cs.synced = time.Now()
+	cs.fallback = false
+}
+
+func (cs *cachedService) replace(instances map[string]InstanceInfo) {
+	cs.instances = instances
+	cs.synced = time.Now()
+	cs.fallback = false
+}
+
+// list returns the instances, sorted by address
+// so that their order is stable
+func (cs *cachedService) list() []InstanceInfo {
+	instances := make([]InstanceInfo, 0, len(cs.instances))
+	for _, instance := range cs.instances {
+		instances = append(instances, instance)
+	}
+	sortInstances(instances)
+	return instances
+}
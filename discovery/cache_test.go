@@ -0,0 +1,96 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/github.com/coreos/etcd/client"
+	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/golang.org/x/net/context"
+)
+
+const testTimeout = time.Duration(5) * time.Second
+
+// fakeKeys serves the instances of the services and
+// sends the events to the watchers
+type fakeKeys struct {
+	client.KeysAPI
+	nodes   client.Nodes
+	events  chan *client.Response
+	stopped chan error
+}
+
+func newFakeKeys(nodes client.Nodes) *fakeKeys {
+	return &fakeKeys{
+		nodes:   nodes,
+		events:  make(chan *client.Response),
+		stopped: make(chan error, 1),
+	}
+}
+
+func (k *fakeKeys) Get(ctx context.Context, key string, opts *client.GetOptions) (*client.Response, error) {
+	return &client.Response{Index: 1, Node: &client.Node{Key: key, Dir: true, Nodes: k.nodes}}, nil
+}
+
+func (k *fakeKeys) Watcher(key string, opts *client.WatcherOptions) client.Watcher {
+	return k
+}
+
+func (k *fakeKeys) Next(ctx context.Context) (*client.Response, error) {
+	select {
+	case resp := <-k.events:
+		return resp, nil
+	case <-ctx.Done():
+		k.stopped <- ctx.Err()
+		return nil, ctx.Err()
+	}
+}
+
+func TestCacheWatch(t *testing.T) {
+	keys := newFakeKeys(client.Nodes{{Key: "mu-sim/b/1", Value: "http://b1"}})
+	c := newInstanceCache(keys, time.Hour)
+
+	instances, err := c.lookup("b")
+	if err != nil || !reflect.DeepEqual(Addresses(instances), []string{"http://b1"}) {
+		t.Fatalf("lookup() = %v, %v, want [http://b1]", instances, err)
+	}
+
+	keys.events <- &client.Response{Action: "set", Node: &client.Node{Key: "mu-sim/b/2", Value: "http://b2", ModifiedIndex: 2}}
+	keys.events <- &client.Response{Action: "expire", Node: &client.Node{Key: "mu-sim/b/1", ModifiedIndex: 3}}
+
+	// The second event is applied before the watch waits for the third one
+	want := []string{"http://b2"}
+	deadline := time.Now().Add(testTimeout)
+	for instances, _ = c.lookup("b"); !reflect.DeepEqual(Addresses(instances), want); instances, _ = c.lookup("b") {
+		if time.Now().After(deadline) {
+			t.Fatalf("lookup() = %v, want %v", instances, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCacheStop(t *testing.T) {
+	keys := newFakeKeys(nil)
+	c := newInstanceCache(keys, time.Hour)
+	if _, err := c.lookup("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	c.stop()
+	select {
+	case err := <-keys.stopped:
+		if err != context.Canceled {
+			t.Errorf("Next() = %v, want the watch canceled", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("The watch did not stop")
+	}
+
+	// A stopped cache does not start new watches
+	if _, err := c.lookup("c"); err != nil {
+		t.Fatal(err)
+	}
+	if c.services["c"].watching {
+		t.Error("The stopped cache started watching c")
+	}
+}
@@ -0,0 +1,97 @@
+package discovery
+
+import (
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNSRegistry finds the instances of a service with the DNS SRV records
+// of _<service>._tcp.<domain>. Every record is an instance listening at
+// http://<target>:<port>, with the weight of the record. The records
+// are cached for maxStale, and if the lookup fails the last known
+// instances are used.
+type DNSRegistry struct {
+	staticRegistration
+	domain   string
+	maxStale time.Duration
+	mutex    sync.Mutex
+	services map[string]*dnsRecords
+}
+
+type dnsRecords struct {
+	instances []InstanceInfo
+	expires   time.Time
+}
+
+func NewDNSRegistry(domain string, maxStale time.Duration) *DNSRegistry {
+	return &DNSRegistry{
+		domain:   domain,
+		maxStale: maxStale,
+		services: make(map[string]*dnsRecords),
+	}
+}
+
+func (r *DNSRegistry) lookup(service string) ([]InstanceInfo, error) {
+	_, records, err := net.LookupSRV(service, "tcp", r.domain)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]InstanceInfo, 0, len(records))
+	for _, srv := range records {
+		host := strings.TrimSuffix(srv.Target, ".")
+		instances = append(instances, InstanceInfo{
+			Address: "http://" + net.JoinHostPort(host, strconv.Itoa(int(srv.Port))),
+			Weight:  float64(srv.Weight),
+		})
+	}
+	sortInstances(instances)
+	return instances, nil
+}
+
+func (r *DNSRegistry) GetAvailableInstances(service string) ([]InstanceInfo, error) {
+	now := time.Now()
+	r.mutex.Lock()
+	cached, ok := r.services[service]
+	var known []InstanceInfo
+	fresh := false
+	if ok {
+		known = cached.instances
+		fresh = now.Before(cached.expires)
+	}
+	r.mutex.Unlock()
+
+	var available []InstanceInfo
+	if fresh {
+		available = known
+	} else {
+		instances, err := r.lookup(service)
+		switch {
+		case err == nil:
+			r.mutex.Lock()
+			r.services[service] = &dnsRecords{instances, now.Add(r.maxStale)}
+			r.mutex.Unlock()
+			available = instances
+		case ok:
+			log.Printf("Cannot look up the instances of %s, using the last known ones: %s\n", service, err)
+			r.mutex.Lock()
+			cached.expires = now.Add(retryInterval)
+			r.mutex.Unlock()
+			available = known
+		default:
+			log.Println(err)
+			return []InstanceInfo{}, err
+		}
+	}
+
+	if len(available) < 1 {
+		log.Println(ErrNoDestinations)
+		return []InstanceInfo{}, ErrNoDestinations
+	}
+
+	return available, nil
+}
@@ -1,7 +1,6 @@
 package discovery
 
 import (
-	"errors"
 	"log"
 	"time"
 
@@ -9,25 +8,29 @@ import (
 	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/golang.org/x/net/context"
 )
 
-var (
-	uuid              string
-	myKey             string
-	myAddress         string
-	kAPI              client.KeysAPI
-	ErrNoDestinations = errors.New("No destinations available")
-)
+// EtcdRegistry stores the instances of the services in an etcd server
+// under the keys mu-sim/<service>/<uuid>
+type EtcdRegistry struct {
+	kAPI     client.KeysAPI
+	cache    *instanceCache
+	myKey    string
+	myRecord string
+}
 
-func InitializeEtcd(uri string) error {
+// NewEtcdRegistry connects to the etcd server. The instances looked up
+// are cached, and read again from etcd only if not confirmed for maxStale
+// (see instanceCache). A maxStale of 0 disables the cache.
+func NewEtcdRegistry(uri string, maxStale time.Duration) (*EtcdRegistry, error) {
 	cfg := client.Config{
 		Endpoints: []string{uri},
 	}
 
 	etcd, err := client.New(cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	kAPI = client.NewKeysAPI(etcd)
+	kAPI := client.NewKeysAPI(etcd)
 
 	//This is needed to probe if the etcd server is reachable
 	_, err = kAPI.Set(
@@ -38,29 +41,34 @@ func InitializeEtcd(uri string) error {
 	)
 	if err != nil {
 		log.Println(err)
-		return err
+		return nil, err
 	}
 
-	return nil
-}
+	r := &EtcdRegistry{kAPI: kAPI}
+	if maxStale > 0 {
+		r.cache = newInstanceCache(kAPI, maxStale)
+	}
 
-func RegisterToEtcd(name string, address string) error {
-	var err error
+	return r, nil
+}
 
-	uuid, err = generateUUID()
+// Register stores the record of the instance, in JSON,
+// under the key of the instance
+func (r *EtcdRegistry) Register(name string, instance InstanceInfo) error {
+	uuid, err := GenerateUUID()
 	if err != nil {
 		log.Println(err)
 		return err
 	}
 
-	myKey = "mu-sim/" + name + "/" + uuid
-	myAddress = address
+	r.myKey = "mu-sim/" + name + "/" + uuid
+	r.myRecord = instance.encode()
 
-	_, err = kAPI.Set(
+	_, err = r.kAPI.Set(
 		context.Background(),
-		myKey,
-		myAddress,
-		&client.SetOptions{TTL: time.Duration(5) * time.Second},
+		r.myKey,
+		r.myRecord,
+		&client.SetOptions{TTL: registrationTTL},
 	)
 	if err != nil {
 		log.Println(err)
@@ -70,26 +78,30 @@ func RegisterToEtcd(name string, address string) error {
 	return nil
 }
 
-func UnregisterFromEtcd() {
-	_, err := kAPI.Delete(context.Background(), myKey, nil)
+func (r *EtcdRegistry) Unregister() {
+	_, err := r.kAPI.Delete(context.Background(), r.myKey, nil)
 	if err != nil {
 		log.Println(err.Error())
 		log.Println("Cannot unregister from etcd")
 	}
+	if r.cache != nil {
+		r.cache.stop()
+	}
 }
 
-func KeepAlive(ch_stop chan struct{}) {
+func (r *EtcdRegistry) KeepAlive(ch_stop chan struct{}) {
 	var err error
-	ticker := time.NewTicker(time.Duration(5) * time.Second)
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			_, err = kAPI.Set(
+			_, err = r.kAPI.Set(
 				context.Background(),
-				myKey,
-				myAddress,
-				&client.SetOptions{TTL: time.Duration(5) * time.Second},
+				r.myKey,
+				r.myRecord,
+				&client.SetOptions{TTL: registrationTTL},
 			)
 			if err != nil {
 				log.Println(err)
@@ -101,22 +113,36 @@ func KeepAlive(ch_stop chan struct{}) {
 	}
 }
 
-func GetAvailableInstances(service string) ([]string, error) {
-	key := "mu-sim/" + service + "/"
-	available := []string{}
-	resp, err := kAPI.Get(context.Background(), key, nil)
+func (r *EtcdRegistry) GetAvailableInstances(service string) ([]InstanceInfo, error) {
+	if r.cache != nil {
+		available, err := r.cache.lookup(service)
+		if err != nil {
+			log.Println(err)
+			return []InstanceInfo{}, err
+		}
+		if len(available) < 1 {
+			log.Println(ErrNoDestinations)
+			return []InstanceInfo{}, ErrNoDestinations
+		}
+		return available, nil
+	}
+
+	key := serviceKey(service)
+	available := []InstanceInfo{}
+	// Sorted, so the order of the instances is stable
+	resp, err := r.kAPI.Get(context.Background(), key, &client.GetOptions{Sort: true})
 	if err != nil {
 		log.Println(err)
-		return []string{}, err
+		return []InstanceInfo{}, err
 	}
 
 	for _, n := range resp.Node.Nodes {
-		available = append(available, n.Value)
+		available = append(available, parseInstance(n.Value))
 	}
 
 	if len(available) < 1 {
 		log.Println(ErrNoDestinations)
-		return []string{}, ErrNoDestinations
+		return []InstanceInfo{}, ErrNoDestinations
 	}
 
 	return available, nil
@@ -0,0 +1,247 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPRegistry stores the instances of the services in a registry
+// server (see RegistryServer), started with "mu-sim registry". As with
+// etcd, the instances looked up are cached and kept current through
+// watches, read again if not confirmed for maxStale and, if the
+// server cannot be reached, the last known ones are used. The
+// watches last until the instance is unregistered.
+type HTTPRegistry struct {
+	url      string
+	maxStale time.Duration
+	client   *http.Client
+	myKey    string
+	myRecord InstanceInfo
+	mutex    sync.Mutex
+	services map[string]*cachedService
+	stopOnce sync.Once
+	ch_stop  chan struct{}
+}
+
+func NewHTTPRegistry(url string, maxStale time.Duration) *HTTPRegistry {
+	return &HTTPRegistry{
+		url:      strings.TrimSuffix(url, "/"),
+		maxStale: maxStale,
+		client:   &http.Client{},
+		services: make(map[string]*cachedService),
+		ch_stop:  make(chan struct{}),
+	}
+}
+
+func (r *HTTPRegistry) do(method string, path string, body interface{}, timeout time.Duration) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	req, err := http.NewRequest(method, r.url+registryPath+path, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	// The requests still running when the watches stop are canceled
+	req.Cancel = r.ch_stop
+	client := *r.client
+	client.Timeout = timeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Registry responded with status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (r *HTTPRegistry) put() error {
+	reg := registration{InstanceInfo: r.myRecord, TTL: int(registrationTTL / time.Second)}
+	resp, err := r.do("PUT", r.myKey, reg, fetchTimeout)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (r *HTTPRegistry) Register(name string, instance InstanceInfo) error {
+	uuid, err := GenerateUUID()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	r.myKey = name + "/" + uuid
+	r.myRecord = instance
+	if err = r.put(); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *HTTPRegistry) Unregister() {
+	defer r.stopWatches()
+
+	resp, err := r.do("DELETE", r.myKey, nil, fetchTimeout)
+	if err != nil {
+		log.Println(err.Error())
+		log.Println("Cannot unregister from the registry")
+		return
+	}
+	resp.Body.Close()
+}
+
+func (r *HTTPRegistry) stopWatches() {
+	r.stopOnce.Do(func() { close(r.ch_stop) })
+}
+
+func (r *HTTPRegistry) stopped() bool {
+	select {
+	case <-r.ch_stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *HTTPRegistry) KeepAlive(ch_stop chan struct{}) {
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.put(); err != nil {
+				log.Println(err)
+				log.Println("Cannot keep the agent Alive")
+			}
+		case <-ch_stop:
+			return
+		}
+	}
+}
+
+// fetch reads the instances of the service. With an index it watches
+// them, waiting until they change after the index (or the timeout).
+func (r *HTTPRegistry) fetch(service string, index uint64, timeout time.Duration) (InstanceList, error) {
+	path := service
+	clientTimeout := fetchTimeout
+	if timeout > 0 {
+		path += "?wait=" + strconv.FormatUint(index, 10) + "&timeout=" + strconv.Itoa(int(timeout/time.Second))
+		clientTimeout += timeout
+	}
+
+	var list InstanceList
+	resp, err := r.do("GET", path, nil, clientTimeout)
+	if err != nil {
+		return list, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&list)
+	return list, err
+}
+
+func (r *HTTPRegistry) GetAvailableInstances(service string) ([]InstanceInfo, error) {
+	available, err := r.lookup(service)
+	if err != nil {
+		log.Println(err)
+		return []InstanceInfo{}, err
+	}
+	if len(available) < 1 {
+		log.Println(ErrNoDestinations)
+		return []InstanceInfo{}, ErrNoDestinations
+	}
+
+	return available, nil
+}
+
+func (r *HTTPRegistry) lookup(service string) ([]InstanceInfo, error) {
+	now := time.Now()
+	r.mutex.Lock()
+	cs, known := r.services[service]
+	if r.maxStale > 0 && known && (now.Sub(cs.synced) <= r.maxStale || now.Before(cs.nextFetch)) {
+		instances := cs.list()
+		r.mutex.Unlock()
+		return instances, nil
+	}
+	r.mutex.Unlock()
+
+	list, err := r.fetch(service, 0, 0)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	cs, known = r.services[service]
+	if err != nil {
+		if !known {
+			return nil, err
+		}
+		if !cs.fallback {
+			log.Printf("Cannot read the instances of %s, using the last known ones: %s\n", service, err)
+			cs.fallback = true
+		}
+		cs.nextFetch = time.Now().Add(retryInterval)
+		return cs.list(), nil
+	}
+
+	if !known {
+		cs = &cachedService{}
+		r.services[service] = cs
+	}
+	cs.replace(list.instances())
+	if r.maxStale > 0 && !cs.watching && !r.stopped() {
+		cs.watching = true
+		go r.watch(service, list.Index)
+	}
+	return cs.list(), nil
+}
+
+// watch keeps the cached instances of the service current. Every answer
+// of the server confirms the instances, even if they did not change.
+// The watch stops when the instance is unregistered.
+func (r *HTTPRegistry) watch(service string, index uint64) {
+	timeout := r.maxStale / 2
+	if timeout < time.Second {
+		timeout = time.Second
+	}
+
+	for {
+		list, err := r.fetch(service, index, timeout)
+		if r.stopped() {
+			return
+		}
+		if err != nil {
+			select {
+			case <-time.After(retryInterval):
+			case <-r.ch_stop:
+				return
+			}
+			continue
+		}
+		index = list.Index
+
+		r.mutex.Lock()
+		r.services[service].replace(list.instances())
+		r.mutex.Unlock()
+	}
+}
+
+func (l InstanceList) instances() map[string]InstanceInfo {
+	instances := make(map[string]InstanceInfo, len(l.Instances))
+	for _, instance := range l.Instances {
+		instances[instance.ID] = instance.InstanceInfo
+	}
+	return instances
+}
@@ -0,0 +1,83 @@
+package discovery
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InstanceInfo is the record of a registered instance of a service.
+// Weight and capacity are 0 when unknown.
+type InstanceInfo struct {
+	Address  string    `json:"address" yaml:"address"`
+	Zone     string    `json:"zone,omitempty" yaml:"zone"`
+	Version  string    `json:"version,omitempty" yaml:"version"`
+	Weight   float64   `json:"weight,omitempty" yaml:"weight"`
+	Capacity int       `json:"capacity,omitempty" yaml:"capacity"`
+	Started  time.Time `json:"started,omitempty" yaml:"started"`
+}
+
+// parseInstance decodes the record of an instance. Records that
+// are not JSON are the bare address of the instance, as stored
+// by the older versions of MuSim.
+func parseInstance(value string) InstanceInfo {
+	var info InstanceInfo
+	if !strings.HasPrefix(value, "{") || json.Unmarshal([]byte(value), &info) != nil {
+		return InstanceInfo{Address: value}
+	}
+	return info
+}
+
+func (info InstanceInfo) encode() string {
+	data, _ := json.Marshal(info)
+	return string(data)
+}
+
+func (info InstanceInfo) equal(other InstanceInfo) bool {
+	return info.Address == other.Address &&
+		info.Zone == other.Zone &&
+		info.Version == other.Version &&
+		info.Weight == other.Weight &&
+		info.Capacity == other.Capacity &&
+		info.Started.Equal(other.Started)
+}
+
+// UnmarshalYAML reads an instance written either as its
+// bare address or as a record with its fields
+func (info *InstanceInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var address string
+	if err := unmarshal(&address); err == nil {
+		*info = InstanceInfo{Address: address}
+		return nil
+	}
+
+	type record InstanceInfo
+	var r record
+	if err := unmarshal(&r); err != nil {
+		return err
+	}
+	*info = InstanceInfo(r)
+	return nil
+}
+
+// Addresses returns the addresses of the instances
+func Addresses(instances []InstanceInfo) []string {
+	addresses := make([]string, len(instances))
+	for i, instance := range instances {
+		addresses[i] = instance.Address
+	}
+	return addresses
+}
+
+// sortInstances sorts the instances by address,
+// so that their order is stable
+func sortInstances(instances []InstanceInfo) {
+	sort.Sort(byAddress(instances))
+}
+
+type byAddress []InstanceInfo
+
+func (l byAddress) Len() int           { return len(l) }
+func (l byAddress) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l byAddress) Less(i, j int) bool { return l[i].Address < l[j].Address }
@@ -0,0 +1,65 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInstance(t *testing.T) {
+	started := time.Date(2016, 5, 10, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  InstanceInfo
+	}{
+		// Older versions store the bare address
+		{"http://10.0.0.1:8080", InstanceInfo{Address: "http://10.0.0.1:8080"}},
+		{"{not json", InstanceInfo{Address: "{not json"}},
+		{`{"address":"http://10.0.0.1:8080"}`, InstanceInfo{Address: "http://10.0.0.1:8080"}},
+		{
+			`{"address":"http://10.0.0.1:8080","zone":"eu-west-1a","version":"v2","weight":3,"capacity":4,"started":"2016-05-10T10:00:00Z"}`,
+			InstanceInfo{"http://10.0.0.1:8080", "eu-west-1a", "v2", 3, 4, started},
+		},
+	}
+
+	for _, test := range tests {
+		if got := parseInstance(test.value); !got.equal(test.want) {
+			t.Errorf("parseInstance(%q) = %+v, want %+v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestInstanceEncode(t *testing.T) {
+	info := InstanceInfo{
+		Address:  "http://10.0.0.1:8080",
+		Zone:     "eu-west-1a",
+		Version:  "v2",
+		Weight:   0.5,
+		Capacity: 4,
+		Started:  time.Now(),
+	}
+	if got := parseInstance(info.encode()); !got.equal(info) {
+		t.Errorf("parseInstance(encode()) = %+v, want %+v", got, info)
+	}
+}
+
+func TestInstanceEqual(t *testing.T) {
+	info := InstanceInfo{Address: "http://10.0.0.1:8080", Zone: "eu-west-1a"}
+	other := info
+	other.Zone = "eu-west-1b"
+	if info.equal(other) {
+		t.Error("equal() of instances in different zones = true")
+	}
+	if !info.equal(info) {
+		t.Error("equal() of the same instance = false")
+	}
+}
+
+func TestSortInstances(t *testing.T) {
+	instances := []InstanceInfo{{Address: "c"}, {Address: "a"}, {Address: "b"}}
+	sortInstances(instances)
+	for i, want := range []string{"a", "b", "c"} {
+		if instances[i].Address != want {
+			t.Fatalf("sortInstances() = %v, want [a b c]", Addresses(instances))
+		}
+	}
+}
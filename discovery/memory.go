@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"log"
+	"sync"
+)
+
+// MemoryDirectory keeps the instances of the services running
+// in the same process. Every service gets its own Registry
+// from the directory.
+type MemoryDirectory struct {
+	mutex     sync.RWMutex
+	instances map[string]map[string]InstanceInfo
+}
+
+type memoryRegistry struct {
+	directory *MemoryDirectory
+	name      string
+	uuid      string
+}
+
+func NewMemoryDirectory() *MemoryDirectory {
+	return &MemoryDirectory{
+		instances: make(map[string]map[string]InstanceInfo),
+	}
+}
+
+func (d *MemoryDirectory) NewRegistry() Registry {
+	return &memoryRegistry{directory: d}
+}
+
+func (d *MemoryDirectory) GetAvailableInstances(service string) ([]InstanceInfo, error) {
+	d.mutex.RLock()
+	available := make([]InstanceInfo, 0, len(d.instances[service]))
+	for _, instance := range d.instances[service] {
+		available = append(available, instance)
+	}
+	d.mutex.RUnlock()
+
+	if len(available) < 1 {
+		log.Println(ErrNoDestinations)
+		return []InstanceInfo{}, ErrNoDestinations
+	}
+
+	// Keep the order stable, as map iteration is random
+	sortInstances(available)
+	return available, nil
+}
+
+func (r *memoryRegistry) Register(name string, instance InstanceInfo) error {
+	uuid, err := GenerateUUID()
+	if err != nil {
+		return err
+	}
+
+	r.name = name
+	r.uuid = uuid
+
+	r.directory.mutex.Lock()
+	if _, ok := r.directory.instances[name]; !ok {
+		r.directory.instances[name] = make(map[string]InstanceInfo)
+	}
+	r.directory.instances[name][uuid] = instance
+	r.directory.mutex.Unlock()
+
+	return nil
+}
+
+// In memory registrations never expire, so there is nothing to keep alive
+func (r *memoryRegistry) KeepAlive(ch_stop chan struct{}) {
+	<-ch_stop
+}
+
+func (r *memoryRegistry) Unregister() {
+	r.directory.mutex.Lock()
+	delete(r.directory.instances[r.name], r.uuid)
+	r.directory.mutex.Unlock()
+}
+
+func (r *memoryRegistry) GetAvailableInstances(service string) ([]InstanceInfo, error) {
+	return r.directory.GetAvailableInstances(service)
+}
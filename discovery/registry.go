@@ -0,0 +1,131 @@
+package discovery
+
+import (
+	"errors"
+	"time"
+)
+
+// Registry keeps track of the available instances of the services.
+// Every service instance uses its own Registry to register itself.
+type Registry interface {
+	Register(name string, instance InstanceInfo) error
+	KeepAlive(ch_stop chan struct{})
+	Unregister()
+	GetAvailableInstances(service string) ([]InstanceInfo, error)
+}
+
+const (
+	// How long a registration lasts if not kept alive
+	registrationTTL = time.Duration(5) * time.Second
+	// How often a registration is renewed, so that a late
+	// renewal does not let it expire
+	keepAliveInterval = registrationTTL / 2
+)
+
+// Providers of the instances of the services
+const (
+	ProviderEtcd     = "etcd"
+	ProviderRegistry = "registry"
+	ProviderFile     = "file"
+	ProviderDNS      = "dns"
+	ProviderStatic   = "static"
+)
+
+var (
+	ErrNoDestinations   = errors.New("No destinations available")
+	ErrInvalidProvider  = errors.New("Invalid discovery provider")
+	ErrMissingDiscovery = errors.New("Discovery provider is not configured")
+)
+
+// Config selects where the instances of the services are found
+type Config struct {
+	Provider    string
+	EtcdAddress string
+	RegistryURL string
+	MaxStale    time.Duration
+	File        string
+	Domain      string
+	Peers       []string
+}
+
+// provider returns the configured provider. By default the static
+// peers are used if any, otherwise the registry server or etcd if
+// their address is set. Without any of them there is no provider.
+func (c Config) provider() string {
+	switch {
+	case c.Provider != "":
+		return c.Provider
+	case len(c.Peers) > 0:
+		return ProviderStatic
+	case c.RegistryURL != "":
+		return ProviderRegistry
+	case c.EtcdAddress != "":
+		return ProviderEtcd
+	default:
+		return ""
+	}
+}
+
+// Describe tells where the instances of the services are found
+func (c Config) Describe() string {
+	switch c.provider() {
+	case ProviderEtcd:
+		return "etcd server at " + c.EtcdAddress
+	case ProviderRegistry:
+		return "registry at " + c.RegistryURL
+	case ProviderFile:
+		return "file " + c.File
+	case ProviderDNS:
+		return "DNS SRV records of " + c.Domain
+	case ProviderStatic:
+		return "static peers"
+	case "":
+		return "discovery provider"
+	default:
+		return "provider " + c.provider()
+	}
+}
+
+// NewRegistry creates the registry of the configured provider
+func NewRegistry(c Config) (Registry, error) {
+	switch c.provider() {
+	case ProviderEtcd:
+		if c.EtcdAddress == "" {
+			return nil, ErrMissingDiscovery
+		}
+		r, err := NewEtcdRegistry(c.EtcdAddress, c.MaxStale)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	case ProviderRegistry:
+		if c.RegistryURL == "" {
+			return nil, ErrMissingDiscovery
+		}
+		return NewHTTPRegistry(c.RegistryURL, c.MaxStale), nil
+	case ProviderFile:
+		if c.File == "" {
+			return nil, ErrMissingDiscovery
+		}
+		r, err := NewFileRegistry(c.File)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	case ProviderDNS:
+		if c.Domain == "" {
+			return nil, ErrMissingDiscovery
+		}
+		return NewDNSRegistry(c.Domain, c.MaxStale), nil
+	case ProviderStatic:
+		r, err := NewStaticRegistry(c.Peers)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	case "":
+		return nil, ErrMissingDiscovery
+	default:
+		return nil, ErrInvalidProvider
+	}
+}
@@ -0,0 +1,288 @@
+package discovery
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	registryPath = "/v1/services/"
+
+	maxWatchTimeout = time.Duration(60) * time.Second
+	expireInterval  = time.Duration(100) * time.Millisecond
+)
+
+// Instance is a registered instance of a service, with its record
+type Instance struct {
+	ID string `json:"id"`
+	InstanceInfo
+}
+
+// InstanceList is the answer of the registry server to a list or a
+// watch: the instances of a service and the index of their last change
+type InstanceList struct {
+	Index     uint64     `json:"index"`
+	Instances []Instance `json:"instances"`
+}
+
+// registration is the body of a register (or keep alive) request:
+// the record of the instance and its ttl
+type registration struct {
+	InstanceInfo
+	TTL int `json:"ttl"`
+}
+
+type registeredInstance struct {
+	info    InstanceInfo
+	expires time.Time
+}
+
+type registeredService struct {
+	index     uint64
+	instances map[string]registeredInstance
+}
+
+// RegistryServer is a small HTTP registry, an alternative to etcd.
+// The instances of the services are kept under /v1/services/<service>/<id>:
+//   - PUT with {"address": ..., "ttl": seconds} registers the instance,
+//     or keeps it alive, for ttl seconds (5 if missing). The body can
+//     also carry the zone, version, weight, capacity and start time
+//     of the instance (see InstanceInfo).
+//   - DELETE unregisters the instance
+//
+// A GET of /v1/services/<service> lists the instances of the service. With
+// the param wait=<index> the request is a watch: it waits until the
+// instances change after that index, at most for timeout=<seconds>.
+type RegistryServer struct {
+	mutex    sync.Mutex
+	index    uint64
+	services map[string]*registeredService
+	// Closed and replaced at every change, to wake up the watches
+	ch_changed chan struct{}
+	ch_stop    chan struct{}
+	server     *http.Server
+}
+
+func NewRegistryServer() *RegistryServer {
+	return &RegistryServer{
+		services:   make(map[string]*registeredService),
+		ch_changed: make(chan struct{}),
+		ch_stop:    make(chan struct{}),
+	}
+}
+
+// Start serves the registry at the address (e.g. ":8400")
+func (s *RegistryServer) Start(address string) error {
+	mux := http.NewServeMux()
+	mux.Handle(registryPath, s)
+	s.server = &http.Server{Addr: address, Handler: mux}
+
+	ch_err := make(chan error, 1)
+	go func() {
+		ch_err <- s.server.ListenAndServe()
+	}()
+	select {
+	case err := <-ch_err:
+		return err
+	case <-time.After(time.Duration(100) * time.Millisecond):
+	}
+
+	go s.expireInstances()
+	return nil
+}
+
+func (s *RegistryServer) Stop() {
+	close(s.ch_stop)
+	s.server.Close()
+}
+
+func (s *RegistryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, registryPath), "/")
+	service := parts[0]
+	if service == "" || len(parts) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(parts) == 1 {
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		s.serveList(w, r, service)
+		return
+	}
+
+	id := parts[1]
+	switch r.Method {
+	case "PUT", "POST":
+		var reg registration
+		if err := json.NewDecoder(r.Body).Decode(&reg); err != nil || reg.Address == "" || reg.TTL < 0 {
+			http.Error(w, "Invalid registration", http.StatusBadRequest)
+			return
+		}
+		if reg.TTL == 0 {
+			reg.TTL = int(registrationTTL / time.Second)
+		}
+		s.register(service, id, reg.InstanceInfo, time.Duration(reg.TTL)*time.Second)
+		w.WriteHeader(http.StatusOK)
+	case "DELETE":
+		if !s.unregister(service, id) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *RegistryServer) serveList(w http.ResponseWriter, r *http.Request, service string) {
+	list := s.list(service)
+
+	if wait := r.URL.Query().Get("wait"); wait != "" {
+		index, err := strconv.ParseUint(wait, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid index", http.StatusBadRequest)
+			return
+		}
+		timeout := maxWatchTimeout
+		if t, err := strconv.Atoi(r.URL.Query().Get("timeout")); err == nil && t > 0 && time.Duration(t)*time.Second < timeout {
+			timeout = time.Duration(t) * time.Second
+		}
+		list = s.watch(service, index, timeout)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(list)
+}
+
+// changed must be called with the mutex held
+func (s *RegistryServer) changed(rs *registeredService) {
+	s.index++
+	rs.index = s.index
+	close(s.ch_changed)
+	s.ch_changed = make(chan struct{})
+}
+
+func (s *RegistryServer) register(service string, id string, info InstanceInfo, ttl time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	rs, ok := s.services[service]
+	if !ok {
+		rs = &registeredService{instances: make(map[string]registeredInstance)}
+		s.services[service] = rs
+	}
+	old, ok := rs.instances[id]
+	rs.instances[id] = registeredInstance{info, time.Now().Add(ttl)}
+	if !ok || !old.info.equal(info) {
+		// Keep alives do not change the instances
+		log.Printf("Registered %s/%s at %s\n", service, id, info.Address)
+		s.changed(rs)
+	}
+}
+
+func (s *RegistryServer) unregister(service string, id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	rs, ok := s.services[service]
+	if !ok {
+		return false
+	}
+	if _, ok = rs.instances[id]; !ok {
+		return false
+	}
+	delete(rs.instances, id)
+	log.Printf("Unregistered %s/%s\n", service, id)
+	s.changed(rs)
+	return true
+}
+
+func (s *RegistryServer) list(service string) InstanceList {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.listLocked(service)
+}
+
+func (s *RegistryServer) listLocked(service string) InstanceList {
+	list := InstanceList{Instances: []Instance{}}
+	rs, ok := s.services[service]
+	if !ok {
+		return list
+	}
+
+	list.Index = rs.index
+	for id, instance := range rs.instances {
+		list.Instances = append(list.Instances, Instance{id, instance.info})
+	}
+	sort.Sort(byID(list.Instances))
+	return list
+}
+
+// watch waits until the instances of the service change after the
+// index, or until the timeout, and returns the instances
+func (s *RegistryServer) watch(service string, index uint64, timeout time.Duration) InstanceList {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		s.mutex.Lock()
+		list := s.listLocked(service)
+		ch_changed := s.ch_changed
+		s.mutex.Unlock()
+		if list.Index > index {
+			return list
+		}
+
+		select {
+		case <-ch_changed:
+		case <-timer.C:
+			return list
+		case <-s.ch_stop:
+			return list
+		}
+	}
+}
+
+// expireInstances removes the instances that were not kept alive
+func (s *RegistryServer) expireInstances() {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.mutex.Lock()
+			for service, rs := range s.services {
+				expired := false
+				for id, instance := range rs.instances {
+					if now.After(instance.expires) {
+						delete(rs.instances, id)
+						log.Printf("Registration of %s/%s expired\n", service, id)
+						expired = true
+					}
+				}
+				if expired {
+					s.changed(rs)
+				}
+			}
+			s.mutex.Unlock()
+		case <-s.ch_stop:
+			return
+		}
+	}
+}
+
+type byID []Instance
+
+func (l byID) Len() int           { return len(l) }
+func (l byID) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l byID) Less(i, j int) bool { return l[i].ID < l[j].ID }
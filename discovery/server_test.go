@@ -0,0 +1,216 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer serves a registry, expiring its instances as Start does
+func newTestServer() (*RegistryServer, *httptest.Server) {
+	s := NewRegistryServer()
+	go s.expireInstances()
+	return s, httptest.NewServer(s)
+}
+
+func stopTestServer(s *RegistryServer, ts *httptest.Server) {
+	close(s.ch_stop)
+	ts.Close()
+}
+
+func call(t *testing.T, method string, url string, body string) *http.Response {
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func getList(t *testing.T, url string) InstanceList {
+	resp := call(t, "GET", url, "")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: status %d", url, resp.StatusCode)
+	}
+
+	var list InstanceList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestRegistryServer(t *testing.T) {
+	s, ts := newTestServer()
+	defer stopTestServer(s, ts)
+	url := ts.URL + registryPath
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"PUT", "b/1", `{"address": "http://b1"}`, http.StatusOK},
+		{"PUT", "b/2", `{"address": "http://b2", "ttl": 60}`, http.StatusOK},
+		{"PUT", "b/3", `{"ttl": 60}`, http.StatusBadRequest},
+		{"PUT", "b/3", `{"address": "http://b3", "ttl": -1}`, http.StatusBadRequest},
+		{"DELETE", "b/2", "", http.StatusOK},
+		{"DELETE", "b/2", "", http.StatusNotFound},
+		{"PUT", "b", `{"address": "http://b1"}`, http.StatusMethodNotAllowed},
+		{"GET", "b/1/x", "", http.StatusNotFound},
+		{"GET", "", "", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		resp := call(t, test.method, url+test.path, test.body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Errorf("%s %s %s: status %d, want %d", test.method, test.path, test.body, resp.StatusCode, test.status)
+		}
+	}
+
+	list := getList(t, url+"b")
+	want := InstanceList{Index: 3, Instances: []Instance{{"1", InstanceInfo{Address: "http://b1"}}}}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("GET b = %+v, want %+v", list, want)
+	}
+
+	// A keep alive does not change the instances
+	call(t, "PUT", url+"b/1", `{"address": "http://b1"}`).Body.Close()
+	if list = getList(t, url+"b"); list.Index != want.Index {
+		t.Errorf("index after a keep alive = %d, want %d", list.Index, want.Index)
+	}
+
+	if list = getList(t, url+"c"); list.Index != 0 || len(list.Instances) != 0 {
+		t.Errorf("GET c = %+v, want no instances", list)
+	}
+}
+
+func TestRegistryServerExpire(t *testing.T) {
+	s, ts := newTestServer()
+	defer stopTestServer(s, ts)
+	url := ts.URL + registryPath
+
+	call(t, "PUT", url+"b/1", `{"address": "http://b1", "ttl": 1}`).Body.Close()
+	if list := getList(t, url+"b"); len(list.Instances) != 1 {
+		t.Fatalf("GET b = %+v, want the registered instance", list)
+	}
+
+	deadline := time.Now().Add(testTimeout)
+	for len(getList(t, url+"b").Instances) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("The registration did not expire")
+		}
+		time.Sleep(time.Duration(50) * time.Millisecond)
+	}
+}
+
+func TestRegistryServerWatch(t *testing.T) {
+	s, ts := newTestServer()
+	defer stopTestServer(s, ts)
+	url := ts.URL + registryPath
+
+	call(t, "PUT", url+"b/1", `{"address": "http://b1"}`).Body.Close()
+
+	// Without changes the watch answers at the timeout
+	start := time.Now()
+	list := getList(t, url+"b?wait=1&timeout=1")
+	if elapsed := time.Since(start); list.Index != 1 || elapsed < time.Second {
+		t.Errorf("watch = %+v after %v, want index 1 after the timeout", list, elapsed)
+	}
+
+	// A change after the index answers the watch
+	ch_list := make(chan InstanceList, 1)
+	go func() {
+		ch_list <- getList(t, url+"b?wait=1&timeout=60")
+	}()
+	time.Sleep(time.Duration(100) * time.Millisecond)
+	call(t, "PUT", url+"b/2", `{"address": "http://b2"}`).Body.Close()
+
+	select {
+	case list = <-ch_list:
+		if list.Index != 2 || len(list.Instances) != 2 {
+			t.Errorf("watch = %+v, want the new instance", list)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("The watch did not see the change")
+	}
+
+	// A change already past the index answers at once
+	if list = getList(t, url+"b?wait=1&timeout=60"); list.Index != 2 {
+		t.Errorf("watch = %+v, want index 2", list)
+	}
+
+	resp := call(t, "GET", url+"b?wait=x", "")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("watch with an invalid index: status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+// countingTransport counts the requests in flight
+type countingTransport struct {
+	inFlight int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&c.inFlight, 1)
+	defer atomic.AddInt32(&c.inFlight, -1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestHTTPRegistry(t *testing.T) {
+	s, ts := newTestServer()
+	defer stopTestServer(s, ts)
+
+	b1 := NewHTTPRegistry(ts.URL, time.Minute)
+	if err := b1.Register("b", InstanceInfo{Address: "http://b1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewHTTPRegistry(ts.URL, time.Minute)
+	transport := &countingTransport{}
+	r.client.Transport = transport
+	instances, err := r.GetAvailableInstances("b")
+	if err != nil || !reflect.DeepEqual(Addresses(instances), []string{"http://b1"}) {
+		t.Fatalf("GetAvailableInstances() = %v, %v, want [http://b1]", instances, err)
+	}
+
+	// The watch brings the new instances, and the ones that left
+	b2 := NewHTTPRegistry(ts.URL, time.Minute)
+	if err = b2.Register("b", InstanceInfo{Address: "http://b2"}); err != nil {
+		t.Fatal(err)
+	}
+	b1.Unregister()
+	want := []string{"http://b2"}
+	deadline := time.Now().Add(testTimeout)
+	for instances, _ = r.GetAvailableInstances("b"); !reflect.DeepEqual(Addresses(instances), want); instances, _ = r.GetAvailableInstances("b") {
+		if time.Now().After(deadline) {
+			t.Fatalf("GetAvailableInstances() = %v, want %v", instances, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Unregistering stops the watch waiting on the server
+	if err = r.Register("a", InstanceInfo{Address: "http://a"}); err != nil {
+		t.Fatal(err)
+	}
+	r.Unregister()
+	deadline = time.Now().Add(testTimeout)
+	for atomic.LoadInt32(&transport.inFlight) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("The watch did not stop")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	b2.Unregister()
+}
@@ -0,0 +1,157 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/gopkg.in/yaml.v2"
+)
+
+const reloadInterval = time.Duration(1) * time.Second
+
+// staticRegistration is embedded by the registries whose instances are
+// listed by the operator: the services cannot register themselves,
+// so registering, keeping alive and unregistering do nothing
+type staticRegistration struct{}
+
+func (staticRegistration) Register(name string, instance InstanceInfo) error { return nil }
+
+func (staticRegistration) KeepAlive(ch_stop chan struct{}) {
+	<-ch_stop
+}
+
+func (staticRegistration) Unregister() {}
+
+// StaticRegistry knows the instances of the services given at start,
+// in the form name=url (e.g. "database=http://10.0.0.1:8080")
+type StaticRegistry struct {
+	staticRegistration
+	instances map[string][]InstanceInfo
+}
+
+func NewStaticRegistry(peers []string) (*StaticRegistry, error) {
+	instances := make(map[string][]InstanceInfo)
+	for _, peer := range peers {
+		i := strings.Index(peer, "=")
+		if i <= 0 || i == len(peer)-1 {
+			return nil, fmt.Errorf("Invalid peer %q: it must be name=url", peer)
+		}
+		instances[peer[:i]] = append(instances[peer[:i]], InstanceInfo{Address: peer[i+1:]})
+	}
+	for _, list := range instances {
+		sortInstances(list)
+	}
+
+	return &StaticRegistry{instances: instances}, nil
+}
+
+func (r *StaticRegistry) GetAvailableInstances(service string) ([]InstanceInfo, error) {
+	available := r.instances[service]
+	if len(available) < 1 {
+		log.Println(ErrNoDestinations)
+		return []InstanceInfo{}, ErrNoDestinations
+	}
+
+	return available, nil
+}
+
+// FileRegistry reads the instances of the services from a YAML (or JSON)
+// file, mapping every service to the list of its instances. An instance
+// is either its URL or a record with its address, zone, version, weight
+// and capacity. The file is read again when it changes, checking at most
+// once a second.
+type FileRegistry struct {
+	staticRegistration
+	path      string
+	mutex     sync.Mutex
+	instances map[string][]InstanceInfo
+	modTime   time.Time
+	size      int64
+	checked   time.Time
+}
+
+func NewFileRegistry(path string) (*FileRegistry, error) {
+	r := &FileRegistry{path: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.load(info); err != nil {
+		return nil, err
+	}
+	r.checked = time.Now()
+
+	return r, nil
+}
+
+func (r *FileRegistry) load(info os.FileInfo) error {
+	data, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	instances := make(map[string][]InstanceInfo)
+	if err = yaml.UnmarshalStrict(data, &instances); err != nil {
+		return err
+	}
+	for service, list := range instances {
+		if len(list) == 0 {
+			return errors.New("Service " + service + " has no instances")
+		}
+		for _, instance := range list {
+			if instance.Address == "" {
+				return errors.New("Service " + service + " has an instance without address")
+			}
+		}
+		sortInstances(list)
+	}
+
+	r.instances = instances
+	r.modTime = info.ModTime()
+	r.size = info.Size()
+	return nil
+}
+
+// reload reads the file again if it changed. If the new
+// file is not valid the old instances are kept.
+func (r *FileRegistry) reload() {
+	now := time.Now()
+	if now.Sub(r.checked) < reloadInterval {
+		return
+	}
+	r.checked = now
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		log.Printf("Cannot read %s, using the last known instances: %s\n", r.path, err)
+		return
+	}
+	if info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return
+	}
+	if err = r.load(info); err != nil {
+		log.Printf("Cannot read %s, using the last known instances: %s\n", r.path, err)
+		return
+	}
+	log.Println("Reloaded the instances from", r.path)
+}
+
+func (r *FileRegistry) GetAvailableInstances(service string) ([]InstanceInfo, error) {
+	r.mutex.Lock()
+	r.reload()
+	available := r.instances[service]
+	r.mutex.Unlock()
+
+	if len(available) < 1 {
+		log.Println(ErrNoDestinations)
+		return []InstanceInfo{}, ErrNoDestinations
+	}
+
+	return available, nil
+}
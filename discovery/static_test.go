@@ -0,0 +1,143 @@
+package discovery
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewStaticRegistry(t *testing.T) {
+	r, err := NewStaticRegistry([]string{
+		"database=http://10.0.0.2:8080",
+		"cache=http://10.0.0.3:8080",
+		"database=http://10.0.0.1:8080",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instances, err := r.GetAvailableInstances("database")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}
+	if got := Addresses(instances); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAvailableInstances() = %v, want %v", got, want)
+	}
+
+	if _, err = r.GetAvailableInstances("queue"); err != ErrNoDestinations {
+		t.Errorf("GetAvailableInstances() of an unknown service = %v, want %v", err, ErrNoDestinations)
+	}
+}
+
+func TestNewStaticRegistryInvalid(t *testing.T) {
+	for _, peer := range []string{"database", "=http://10.0.0.1:8080", "database="} {
+		if _, err := NewStaticRegistry([]string{peer}); err == nil {
+			t.Errorf("NewStaticRegistry(%q), want an error", peer)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path string, data string) {
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mu-sim-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "instances.yaml")
+
+	writeFile(t, path, `
+database:
+  - http://10.0.0.2:8080
+  - address: http://10.0.0.1:8080
+    zone: eu-west-1a
+    weight: 3
+`)
+	r, err := NewFileRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances, err := r.GetAvailableInstances("database")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []InstanceInfo{
+		{Address: "http://10.0.0.1:8080", Zone: "eu-west-1a", Weight: 3},
+		{Address: "http://10.0.0.2:8080"},
+	}
+	if !reflect.DeepEqual(instances, want) {
+		t.Errorf("GetAvailableInstances() = %+v, want %+v", instances, want)
+	}
+
+	// An invalid file keeps the last known instances
+	writeFile(t, path, "database: [{zone: eu-west-1a}]\n")
+	r.checked = time.Time{}
+	if instances, err = r.GetAvailableInstances("database"); err != nil || len(instances) != 2 {
+		t.Errorf("GetAvailableInstances() after an invalid change = %+v, %v, want the last known", instances, err)
+	}
+
+	writeFile(t, path, "cache: [http://10.0.0.3:8080]\n")
+	r.checked = time.Time{}
+	if _, err = r.GetAvailableInstances("database"); err != ErrNoDestinations {
+		t.Errorf("GetAvailableInstances() after a reload = %v, want %v", err, ErrNoDestinations)
+	}
+}
+
+func TestNewFileRegistryInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mu-sim-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "instances.yaml")
+
+	for _, data := range []string{
+		"database: []\n",
+		"database: [{zone: eu-west-1a}]\n",
+		"database: http://10.0.0.1:8080\n",
+		"database: [{address: http://10.0.0.1:8080, rack: 3}]\n",
+	} {
+		writeFile(t, path, data)
+		if _, err = NewFileRegistry(path); err == nil {
+			t.Errorf("NewFileRegistry() of %q, want an error", data)
+		}
+	}
+}
+
+func TestConfigProvider(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want string
+	}{
+		{Config{Provider: ProviderDNS, Domain: "example.com"}, ProviderDNS},
+		{Config{Peers: []string{"a=http://a"}, EtcdAddress: "http://etcd"}, ProviderStatic},
+		{Config{RegistryURL: "http://registry", EtcdAddress: "http://etcd"}, ProviderRegistry},
+		{Config{EtcdAddress: "http://etcd"}, ProviderEtcd},
+		{Config{}, ""},
+	}
+
+	for _, test := range tests {
+		if got := test.cfg.provider(); got != test.want {
+			t.Errorf("provider(%+v) = %q, want %q", test.cfg, got, test.want)
+		}
+	}
+
+	if _, err := NewRegistry(Config{}); err != ErrMissingDiscovery {
+		t.Errorf("NewRegistry() without a provider = %v, want %v", err, ErrMissingDiscovery)
+	}
+	if _, err := NewRegistry(Config{Provider: ProviderFile}); err != ErrMissingDiscovery {
+		t.Errorf("NewRegistry() without a file = %v, want %v", err, ErrMissingDiscovery)
+	}
+	if _, err := NewRegistry(Config{Provider: "zookeeper"}); err != ErrInvalidProvider {
+		t.Errorf("NewRegistry() of an unknown provider = %v, want %v", err, ErrInvalidProvider)
+	}
+}
@@ -5,7 +5,8 @@ import (
 	"encoding/hex"
 )
 
-func generateUUID() (string, error) {
+// GenerateUUID creates a random (version 4) UUID
+func GenerateUUID() (string, error) {
 	u := make([]byte, 16)
 	_, err := rand.Read(u)
 	if err != nil {
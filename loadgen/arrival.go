@@ -0,0 +1,89 @@
+package loadgen
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Arrival patterns of the open-loop generator
+const (
+	PatternPoisson  = "poisson"
+	PatternConstant = "constant"
+	PatternStep     = "step"
+	PatternRamp     = "ramp"
+)
+
+var ErrInvalidPattern = errors.New("Invalid arrival pattern")
+
+// arrivals generates the time between two consecutive requests.
+// Step and ramp patterns change the rate during the run and use
+// Poisson arrivals at the current rate.
+type arrivals struct {
+	pattern  string
+	rate     float64
+	endRate  float64
+	steps    int
+	duration time.Duration
+	gen      *rand.Rand
+}
+
+func newArrivals(opts Options) (*arrivals, error) {
+	switch opts.Pattern {
+	case PatternPoisson, PatternConstant, PatternStep, PatternRamp:
+	default:
+		return nil, ErrInvalidPattern
+	}
+	if opts.Rate <= 0 {
+		return nil, errors.New("Rate must be positive")
+	}
+	if (opts.Pattern == PatternStep || opts.Pattern == PatternRamp) && opts.EndRate <= 0 {
+		return nil, errors.New("End rate of step and ramp patterns must be positive")
+	}
+	if opts.Pattern == PatternStep && opts.Steps <= 0 {
+		return nil, errors.New("Number of steps must be positive")
+	}
+
+	return &arrivals{
+		pattern:  opts.Pattern,
+		rate:     opts.Rate,
+		endRate:  opts.EndRate,
+		steps:    opts.Steps,
+		duration: opts.Duration,
+		gen:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// rateAt returns the rate, in requests per second, at the given time of the run
+func (a *arrivals) rateAt(elapsed time.Duration) float64 {
+	progress := math.Min(1, float64(elapsed)/float64(a.duration))
+	switch a.pattern {
+	case PatternStep:
+		step := math.Min(float64(a.steps-1), math.Floor(progress*float64(a.steps)))
+		if a.steps == 1 {
+			return a.rate
+		}
+		return a.rate + (a.endRate-a.rate)*step/float64(a.steps-1)
+	case PatternRamp:
+		return a.rate + (a.endRate-a.rate)*progress
+	default:
+		return a.rate
+	}
+}
+
+// next returns the time to wait before sending the next request
+func (a *arrivals) next(elapsed time.Duration) time.Duration {
+	rate := a.rateAt(elapsed)
+	if rate <= 0 {
+		// Nothing to send at the moment: check again later
+		return time.Duration(100) * time.Millisecond
+	}
+
+	interval := 1 / rate
+	if a.pattern != PatternConstant {
+		interval = a.gen.ExpFloat64() / rate
+	}
+
+	return time.Duration(interval * float64(time.Second))
+}
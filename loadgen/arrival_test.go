@@ -0,0 +1,70 @@
+package loadgen
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewArrivals(t *testing.T) {
+	tests := []struct {
+		opts  Options
+		valid bool
+	}{
+		{Options{Pattern: PatternPoisson, Rate: 10}, true},
+		{Options{Pattern: PatternConstant, Rate: 10}, true},
+		{Options{Pattern: PatternStep, Rate: 10, EndRate: 50, Steps: 5}, true},
+		{Options{Pattern: PatternRamp, Rate: 10, EndRate: 5}, true},
+		{Options{Pattern: "burst", Rate: 10}, false},
+		{Options{Pattern: PatternPoisson, Rate: 0}, false},
+		{Options{Pattern: PatternConstant, Rate: -1, EndRate: 10}, false},
+		{Options{Pattern: PatternRamp, Rate: 10}, false},
+		{Options{Pattern: PatternStep, Rate: 10, EndRate: 50}, false},
+		{Options{Pattern: PatternRamp, Rate: 0, EndRate: 50}, false},
+	}
+
+	for _, test := range tests {
+		_, err := newArrivals(test.opts)
+		if (err == nil) != test.valid {
+			t.Errorf("newArrivals(%+v) = %v, want valid %t", test.opts, err, test.valid)
+		}
+	}
+}
+
+func TestRateAt(t *testing.T) {
+	minute := time.Minute
+	tests := []struct {
+		opts    Options
+		elapsed time.Duration
+		want    float64
+	}{
+		{Options{Pattern: PatternConstant, Rate: 10}, minute / 2, 10},
+		{Options{Pattern: PatternRamp, Rate: 10, EndRate: 50}, 0, 10},
+		{Options{Pattern: PatternRamp, Rate: 10, EndRate: 50}, minute / 2, 30},
+		{Options{Pattern: PatternRamp, Rate: 10, EndRate: 50}, 2 * minute, 50},
+		{Options{Pattern: PatternStep, Rate: 10, EndRate: 50, Steps: 5}, 0, 10},
+		{Options{Pattern: PatternStep, Rate: 10, EndRate: 50, Steps: 5}, minute / 2, 30},
+		{Options{Pattern: PatternStep, Rate: 10, EndRate: 50, Steps: 5}, minute - time.Second, 50},
+		{Options{Pattern: PatternStep, Rate: 10, EndRate: 50, Steps: 1}, minute / 2, 10},
+	}
+
+	for _, test := range tests {
+		test.opts.Duration = minute
+		a, err := newArrivals(test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.rateAt(test.elapsed); got != test.want {
+			t.Errorf("%s rateAt(%s) = %f, want %f", test.opts.Pattern, test.elapsed, got, test.want)
+		}
+	}
+}
+
+func TestConstantArrivals(t *testing.T) {
+	a, err := newArrivals(Options{Pattern: PatternConstant, Rate: 4, Duration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := a.next(0); got != time.Duration(250)*time.Millisecond {
+		t.Errorf("next() = %s, want 250ms", got)
+	}
+}
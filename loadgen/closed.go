@@ -0,0 +1,82 @@
+package loadgen
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var ErrNoUsers = errors.New("Number of users must be positive")
+
+// RunClosed simulates a fixed number of virtual users: every user sends
+// a request, waits for its response, thinks and then sends the next one.
+// A user waits for a response at most for the wait time of the test.
+func (g *Generator) RunClosed() (*Report, error) {
+	if g.opts.Users <= 0 {
+		return nil, ErrNoUsers
+	}
+
+	if err := g.start(); err != nil {
+		return nil, err
+	}
+	defer g.stop()
+
+	log.Printf("Simulating %d users of %s for %s\n", g.opts.Users, g.opts.Target, g.opts.Duration)
+	end := time.Now().Add(g.opts.Duration)
+	var wg sync.WaitGroup
+	for i := 0; i < g.opts.Users; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			g.user(end, rand.New(rand.NewSource(seed)))
+		}(time.Now().UnixNano() + int64(i))
+	}
+	wg.Wait()
+
+	g.drain()
+	return g.report, nil
+}
+
+func (g *Generator) user(end time.Time, gen *rand.Rand) {
+	ch_resp := make(chan string, 1)
+
+	// Users start thinking, so they do not send their first requests all together
+	g.think(gen, end)
+	for time.Now().Before(end) {
+		id, err := g.send(g.opts.Service, ch_resp)
+		if err == nil {
+			g.waitResponse(id, ch_resp)
+		}
+		g.think(gen, end)
+	}
+}
+
+func (g *Generator) waitResponse(id string, ch_resp chan string) {
+	timeout := time.NewTimer(g.opts.Wait)
+	defer timeout.Stop()
+
+	select {
+	case <-ch_resp:
+	case <-timeout.C:
+		if _, ok := g.complete(id); ok {
+			g.report.addLost()
+			return
+		}
+		// The response arrived in the meanwhile
+		<-ch_resp
+	}
+}
+
+func (g *Generator) think(gen *rand.Rand, end time.Time) {
+	if g.opts.Think == nil {
+		return
+	}
+
+	d := time.Duration(g.opts.Think.Sample(gen) * float64(time.Millisecond))
+	if remaining := end.Sub(time.Now()); d > remaining {
+		d = remaining
+	}
+	time.Sleep(d)
+}
@@ -0,0 +1,232 @@
+package loadgen
+
+import (
+	"log"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/discovery"
+	"github.com/elleFlorio/mu-sim/network"
+	"github.com/elleFlorio/mu-sim/worker"
+)
+
+// Resolver finds the instances of the target service
+type Resolver interface {
+	GetAvailableInstances(service string) ([]discovery.InstanceInfo, error)
+}
+
+// StaticResolver always resolves to the same address
+type StaticResolver string
+
+func (r StaticResolver) GetAvailableInstances(service string) ([]discovery.InstanceInfo, error) {
+	return []discovery.InstanceInfo{{Address: string(r)}}, nil
+}
+
+// Options of a load test
+type Options struct {
+	Target string
+	// Number of virtual users of the closed-loop generator
+	Users int
+	// Think time of the virtual users
+	Think    worker.Distribution
+	Service  string
+	Pattern  string
+	Rate     float64
+	EndRate  float64
+	Steps    int
+	Duration time.Duration
+	Wait     time.Duration
+}
+
+const refreshInterval = time.Duration(5) * time.Second
+
+// Generator sends requests to the target service and receives their
+// responses, measuring the end-to-end latency
+type Generator struct {
+	opts      Options
+	address   string
+	transport network.Transport
+	resolver  Resolver
+	prefix    string
+	counter   int
+	mutex     sync.Mutex
+	instances []discovery.InstanceInfo
+	resolved  time.Time
+	pending   map[string]pendingRequest
+	sending   sync.WaitGroup
+	report    *Report
+}
+
+type pendingRequest struct {
+	start   time.Time
+	ch_resp chan string
+}
+
+func New(opts Options, address string, transport network.Transport, resolver Resolver) *Generator {
+	return &Generator{
+		opts:      opts,
+		address:   address,
+		transport: transport,
+		resolver:  resolver,
+		prefix:    "load-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+		pending:   make(map[string]pendingRequest),
+	}
+}
+
+// Run sends requests following the arrival pattern for the whole
+// duration of the test, then waits for the pending responses
+func (g *Generator) Run() (*Report, error) {
+	a, err := newArrivals(g.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = g.start(); err != nil {
+		return nil, err
+	}
+	defer g.stop()
+
+	log.Printf("Sending requests to %s for %s\n", g.opts.Target, g.opts.Duration)
+	start := time.Now()
+	// Arrivals are scheduled from the start of the run,
+	// so the time spent sending does not skew the rate
+	at := time.Duration(0)
+	for {
+		at += a.next(at)
+		if at >= g.opts.Duration {
+			break
+		}
+		time.Sleep(at - time.Since(start))
+		g.dispatch(g.opts.Service)
+	}
+	time.Sleep(g.opts.Duration - time.Since(start))
+
+	g.drain()
+	return g.report, nil
+}
+
+func (g *Generator) start() error {
+	if err := g.transport.Listen(g.address, g); err != nil {
+		return err
+	}
+	log.Println("Waiting for responses on", g.address)
+	g.report = newReport()
+
+	return nil
+}
+
+func (g *Generator) stop() {
+	g.transport.Close(g.address)
+}
+
+// drain waits for the pending responses, at most for the wait time
+func (g *Generator) drain() {
+	g.sending.Wait()
+	log.Println("Waiting for pending responses...")
+	deadline := time.Now().Add(g.opts.Wait)
+	for g.pendingCount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Duration(100) * time.Millisecond)
+	}
+	g.report.finish(g.pendingCount())
+}
+
+// dispatch sends a new request in background
+func (g *Generator) dispatch(service string) {
+	g.sending.Add(1)
+	go func() {
+		defer g.sending.Done()
+		g.send(service, nil)
+	}()
+}
+
+// send dispatches a new request to an instance of the target, that will
+// forward it to service, returning its ID. If ch_resp is not nil it
+// receives the body of the response.
+func (g *Generator) send(service string, ch_resp chan string) (string, error) {
+	dest, err := g.destination()
+	if err != nil {
+		g.report.addSendError()
+		return "", err
+	}
+
+	g.mutex.Lock()
+	id := g.prefix + "-" + strconv.Itoa(g.counter)
+	g.counter++
+	g.pending[id] = pendingRequest{time.Now(), ch_resp}
+	g.mutex.Unlock()
+
+	message := network.Message{
+		Sender: g.address,
+		Body:   "do",
+		Args:   id,
+	}
+	g.report.addSent()
+	if err = g.transport.SendMessage(dest, message, service); err != nil {
+		p, _ := g.complete(id)
+		if err == network.ErrOverloaded {
+			// The target refused the request, so it is not answered
+			g.report.addResponse(time.Since(p.start).Seconds()*1000, "rejected")
+			return id, err
+		}
+		log.Printf("Cannot send request %s to %s: %s\n", id, dest, err.Error())
+		g.report.addSendError()
+		return id, err
+	}
+
+	return id, nil
+}
+
+// destination chooses a random instance of the target,
+// refreshing periodically the list of the available ones
+func (g *Generator) destination() (string, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if len(g.instances) == 0 || time.Since(g.resolved) > refreshInterval {
+		instances, err := g.resolver.GetAvailableInstances(g.opts.Target)
+		if err != nil && len(g.instances) == 0 {
+			return "", err
+		}
+		if err == nil {
+			g.instances = instances
+			g.resolved = time.Now()
+		}
+	}
+
+	return g.instances[rand.Intn(len(g.instances))].Address, nil
+}
+
+func (g *Generator) complete(id string) (pendingRequest, bool) {
+	g.mutex.Lock()
+	p, ok := g.pending[id]
+	delete(g.pending, id)
+	g.mutex.Unlock()
+	return p, ok
+}
+
+func (g *Generator) pendingCount() int {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return len(g.pending)
+}
+
+func (g *Generator) HandleMessage(message network.Message, service string) error {
+	return network.ErrUnprocessable
+}
+
+// HandleResponse records the end-to-end latency of a request
+func (g *Generator) HandleResponse(message network.Message) error {
+	p, ok := g.complete(message.Args)
+	if !ok {
+		return network.ErrUnprocessable
+	}
+
+	g.report.addResponse(time.Since(p.start).Seconds()*1000, message.Body)
+	if p.ch_resp != nil {
+		p.ch_resp <- message.Body
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package loadgen
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"time"
+)
+
+// Record is a request of a trace. Timestamp is either an RFC3339
+// string or a number of seconds since the Unix epoch. Service is the
+// service the target should forward the request to: if it is empty the
+// service of the options is used.
+type Record struct {
+	Timestamp interface{} `json:"timestamp"`
+	Service   string      `json:"service"`
+}
+
+const maxRecordSize = 1048576
+
+var ErrInvalidSpeedup = errors.New("Speed-up factor must be positive")
+
+// Replay sends the requests of a trace of JSON records, one per line,
+// preserving their inter-arrival times divided by the speed-up factor.
+// Records are expected in chronological order: the ones older than
+// the previous record are sent immediately.
+func (g *Generator) Replay(trace io.Reader, speedup float64) (*Report, error) {
+	if speedup <= 0 {
+		return nil, ErrInvalidSpeedup
+	}
+
+	if err := g.start(); err != nil {
+		return nil, err
+	}
+	defer g.stop()
+
+	log.Printf("Replaying trace against %s (speed-up %g)\n", g.opts.Target, speedup)
+	scanner := bufio.NewScanner(trace)
+	scanner.Buffer(make([]byte, 4096), maxRecordSize)
+
+	var first time.Time
+	var start time.Time
+	line := 0
+	invalid := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf("Invalid record at line %d: %s\n", line, err.Error())
+			invalid++
+			continue
+		}
+		ts, err := record.time()
+		if err != nil {
+			log.Printf("Invalid record at line %d: %s\n", line, err.Error())
+			invalid++
+			continue
+		}
+
+		if start.IsZero() {
+			first = ts
+			start = time.Now()
+		}
+		at := time.Duration(float64(ts.Sub(first)) / speedup)
+		time.Sleep(at - time.Since(start))
+		service := record.Service
+		if service == "" {
+			service = g.opts.Service
+		}
+		g.dispatch(service)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if invalid > 0 {
+		log.Printf("Skipped %d invalid records\n", invalid)
+	}
+
+	g.drain()
+	return g.report, nil
+}
+
+func (r Record) time() (time.Time, error) {
+	switch ts := r.Timestamp.(type) {
+	case string:
+		return time.Parse(time.RFC3339Nano, ts)
+	case float64:
+		sec, frac := math.Modf(ts)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid timestamp %v", r.Timestamp)
+	}
+}
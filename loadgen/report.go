@@ -0,0 +1,153 @@
+package loadgen
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upper bounds of the buckets of the latency histogram, in ms
+var buckets = []float64{
+	1, 2, 5, 10, 20, 50, 100, 200, 500,
+	1000, 2000, 5000, 10000, 20000, 50000, 100000,
+}
+
+const histogramWidth = 50
+
+// Report collects the results of a run
+type Report struct {
+	mutex     sync.Mutex
+	start     time.Time
+	end       time.Time
+	sent      int
+	sendErrs  int
+	failed    map[string]int
+	lost      int
+	latencies []float64
+}
+
+func newReport() *Report {
+	return &Report{
+		start:  time.Now(),
+		failed: make(map[string]int),
+	}
+}
+
+func (r *Report) addSent() {
+	r.mutex.Lock()
+	r.sent++
+	r.mutex.Unlock()
+}
+
+func (r *Report) addSendError() {
+	r.mutex.Lock()
+	r.sendErrs++
+	r.mutex.Unlock()
+}
+
+func (r *Report) addResponse(latencyMs float64, body string) {
+	r.mutex.Lock()
+	if body == "done" {
+		r.latencies = append(r.latencies, latencyMs)
+	} else {
+		r.failed[body]++
+	}
+	r.mutex.Unlock()
+}
+
+func (r *Report) addLost() {
+	r.mutex.Lock()
+	r.lost++
+	r.mutex.Unlock()
+}
+
+func (r *Report) finish(lost int) {
+	r.mutex.Lock()
+	r.end = time.Now()
+	r.lost += lost
+	r.mutex.Unlock()
+}
+
+// Print writes the summary of the run and the latency histogram
+func (r *Report) Print(w io.Writer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	elapsed := r.end.Sub(r.start).Seconds()
+	completed := len(r.latencies)
+	failed := 0
+	for _, n := range r.failed {
+		failed += n
+	}
+
+	fmt.Fprintf(w, "Duration:    %.2fs\n", elapsed)
+	fmt.Fprintf(w, "Sent:        %d\n", r.sent)
+	fmt.Fprintf(w, "Completed:   %d\n", completed)
+	fmt.Fprintf(w, "Failed:      %d\n", failed)
+	for body, n := range r.failed {
+		fmt.Fprintf(w, "  %-10s %d\n", body+":", n)
+	}
+	fmt.Fprintf(w, "Send errors: %d\n", r.sendErrs)
+	fmt.Fprintf(w, "Lost:        %d\n", r.lost)
+	if elapsed > 0 {
+		fmt.Fprintf(w, "Throughput:  %.2f req/s\n", float64(completed)/elapsed)
+	}
+
+	if completed == 0 {
+		return
+	}
+
+	sorted := make([]float64, completed)
+	copy(sorted, r.latencies)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, l := range sorted {
+		sum += l
+	}
+
+	fmt.Fprintln(w, "\nLatency (ms):")
+	fmt.Fprintf(w, "  min %.2f  mean %.2f  p50 %.2f  p90 %.2f  p99 %.2f  max %.2f\n",
+		sorted[0], sum/float64(completed),
+		percentile(sorted, 50), percentile(sorted, 90), percentile(sorted, 99),
+		sorted[completed-1])
+
+	counts := make([]int, len(buckets)+1)
+	for _, l := range sorted {
+		counts[sort.SearchFloat64s(buckets, l)]++
+	}
+	max := 0
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+
+	fmt.Fprintln(w, "\nHistogram (ms):")
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		label := "> " + formatBound(buckets[len(buckets)-1])
+		if i < len(buckets) {
+			label = "<= " + formatBound(buckets[i])
+		}
+		bar := strings.Repeat("#", int(math.Ceil(float64(c)*histogramWidth/float64(max))))
+		fmt.Fprintf(w, "  %9s %8d %s\n", label, c, bar)
+	}
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+func formatBound(b float64) string {
+	return fmt.Sprintf("%g", b)
+}
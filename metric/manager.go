@@ -14,74 +14,131 @@ type InfluxConfig struct {
 	Password string
 }
 
-var (
-	tags           map[string]string
-	execFields     map[string]interface{}
-	respTimeFields map[string]interface{}
-	influx         client.Client
-	config         InfluxConfig
-	batch          client.BatchPoints
-)
+// Recorder sends the metrics of a service to influxdb
+type Recorder struct {
+	tags   map[string]string
+	influx client.Client
+	config InfluxConfig
+}
 
-func Initialize(serviceName string, serviceWorkload string, serviceAddress string, influxConf InfluxConfig) (bool, error) {
-	if influxConf.Address == "" {
-		return false, errors.New("Metric service not configured")
-	}
+var ErrNotConfigured = errors.New("Metric service not configured")
 
-	var err error
-	tags = map[string]string{
-		"name":     serviceName,
-		"workload": serviceWorkload,
-		"address":  serviceAddress,
-	}
-	execFields = map[string]interface{}{
-		"value": 0.0,
-	}
-	respTimeFields = map[string]interface{}{
-		"value": 0.0,
+func New(serviceName string, serviceWorkload string, serviceAddress string, influxConf InfluxConfig) (*Recorder, error) {
+	if influxConf.Address == "" {
+		return nil, ErrNotConfigured
 	}
-	config = influxConf
 
-	influx, err = client.NewHTTPClient(client.HTTPConfig{
-		Addr:     config.Address,
-		Username: config.Username,
-		Password: config.Password,
+	influx, err := client.NewHTTPClient(client.HTTPConfig{
+		Addr:     influxConf.Address,
+		Username: influxConf.Username,
+		Password: influxConf.Password,
 	})
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	batch, err = client.NewBatchPoints(client.BatchPointsConfig{
-		Database:  config.DBname,
-		Precision: "ms",
-	})
-	if err != nil {
-		return false, err
+	r := &Recorder{
+		tags: map[string]string{
+			"name":     serviceName,
+			"workload": serviceWorkload,
+			"address":  serviceAddress,
+		},
+		influx: influx,
+		config: influxConf,
 	}
 
-	return true, nil
+	return r, nil
 }
 
-func SendExecutionTime(execTime float64) error {
-	execFields["value"] = execTime
-	point, err := client.NewPoint("execution_time", tags, execFields, time.Now())
-	if err != nil {
-		return err
+func (r *Recorder) SendExecutionTime(execTime float64) error {
+	return r.send("execution_time", execTime)
+}
+
+func (r *Recorder) SendResponseTime(respTime float64) error {
+	return r.send("response_time", respTime)
+}
+
+func (r *Recorder) SendQueueTime(queueTime float64) error {
+	return r.send("queue_time", queueTime)
+}
+
+func (r *Recorder) SendQueueLength(queueLength int) error {
+	return r.send("queue_length", float64(queueLength))
+}
+
+func (r *Recorder) SendRejection() error {
+	return r.send("rejections", 1)
+}
+
+func (r *Recorder) SendTimeout() error {
+	return r.send("timeouts", 1)
+}
+
+func (r *Recorder) SendLookupTime(lookupTime float64) error {
+	return r.send("discovery_lookup_time", lookupTime)
+}
+
+func (r *Recorder) SendRetry() error {
+	return r.send("retries", 1)
+}
+
+// SendBreakerState records the new state of the circuit breaker
+// of a destination: 0 is closed, 1 is half-open and 2 is open
+func (r *Recorder) SendBreakerState(destination string, state string) error {
+	value := 0.0
+	switch state {
+	case "half-open":
+		value = 1
+	case "open":
+		value = 2
 	}
+	return r.sendTagged("breaker_state", value, map[string]string{
+		"destination": destination,
+		"state":       state,
+	})
+}
 
-	batch.AddPoint(point)
-	influx.Write(batch)
-	return nil
+func (r *Recorder) SendIOTime(ioTime float64) error {
+	return r.send("io_time", ioTime)
+}
+
+func (r *Recorder) SendIOBytes(ioBytes int64) error {
+	return r.send("io_bytes", float64(ioBytes))
 }
 
-func SendResponseTime(respTime float64) error {
-	respTimeFields["value"] = respTime
-	point, err := client.NewPoint("response_time", tags, respTimeFields, time.Now())
+func (r *Recorder) send(measurement string, value float64) error {
+	return r.sendTagged(measurement, value, nil)
+}
+
+// sendTagged sends a point with some tags besides the ones of the service
+func (r *Recorder) sendTagged(measurement string, value float64, extra map[string]string) error {
+	tags := r.tags
+	if len(extra) > 0 {
+		tags = make(map[string]string, len(r.tags)+len(extra))
+		for k, v := range r.tags {
+			tags[k] = v
+		}
+		for k, v := range extra {
+			tags[k] = v
+		}
+	}
+
+	batch, err := client.NewBatchPoints(client.BatchPointsConfig{
+		Database:  r.config.DBname,
+		Precision: "ms",
+	})
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{
+		"value": value,
+	}
+	point, err := client.NewPoint(measurement, tags, fields, time.Now())
 	if err != nil {
 		return err
 	}
 
 	batch.AddPoint(point)
-	influx.Write(batch)
-	return nil
+	return r.influx.Write(batch)
 }
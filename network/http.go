@@ -0,0 +1,182 @@
+package network
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+const (
+	messagePath  = "/message"
+	responsePath = "/response"
+)
+
+// HTTPTransport delivers messages as JSON documents POSTed
+// to the /message and /response endpoints of the services
+type HTTPTransport struct {
+	client    *http.Client
+	mutex     sync.Mutex
+	listeners map[string]net.Listener
+	muxes     map[string]*http.ServeMux
+}
+
+func NewHTTPTransport() *HTTPTransport {
+	return &HTTPTransport{
+		client:    &http.Client{},
+		listeners: make(map[string]net.Listener),
+		muxes:     make(map[string]*http.ServeMux),
+	}
+}
+
+// Listen starts serving the requests sent to address in background
+func (t *HTTPTransport) Listen(address string, h Handler) error {
+	u, err := url.Parse(address)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", ":"+u.Port())
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(responsePath, func(w http.ResponseWriter, r *http.Request) {
+		readResponse(h, w, r)
+	})
+	mux.HandleFunc(messagePath, func(w http.ResponseWriter, r *http.Request) {
+		readMessage(h, w, r)
+	})
+
+	t.mutex.Lock()
+	t.listeners[address] = l
+	t.muxes[address] = mux
+	t.mutex.Unlock()
+
+	go func() {
+		err := http.Serve(l, mux)
+		log.Println("Stopped listening on", address, err)
+	}()
+
+	return nil
+}
+
+func (t *HTTPTransport) Close(address string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if l, ok := t.listeners[address]; ok {
+		l.Close()
+		delete(t.listeners, address)
+		delete(t.muxes, address)
+	}
+}
+
+// Handle adds an endpoint to the service listening on address
+func (t *HTTPTransport) Handle(address string, pattern string, handler http.Handler) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	mux, ok := t.muxes[address]
+	if !ok {
+		return ErrUnknownDestination
+	}
+	mux.Handle(pattern, handler)
+
+	return nil
+}
+
+func (t *HTTPTransport) SendMessage(address string, message Message, service string) error {
+	path := address + messagePath
+	if service != "" {
+		path += "?service=" + url.QueryEscape(service)
+	}
+
+	return t.post(path, message)
+}
+
+func (t *HTTPTransport) SendResponse(address string, message Message) error {
+	return t.post(address+responsePath, message)
+}
+
+func (t *HTTPTransport) post(path string, message Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", path, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return ErrOverloaded
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded with status %d", path, resp.StatusCode)
+	}
+
+	return nil
+}
+
+func readMessage(h Handler, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	message, err := ReadMessage(r)
+	if err != nil {
+		log.Println("Cannot read message")
+		w.WriteHeader(422)
+		return
+	}
+
+	service, err := ReadParam("service", r)
+	if err != nil {
+		log.Println("Cannot read param 'service'")
+	}
+
+	if err = h.HandleMessage(message, service); err != nil {
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func readResponse(h Handler, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	message, err := ReadMessage(r)
+	if err != nil {
+		log.Println("Cannot read message")
+		w.WriteHeader(422)
+		return
+	}
+
+	if err = h.HandleResponse(message); err != nil {
+		w.WriteHeader(statusFromError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func statusFromError(err error) int {
+	switch err {
+	case ErrOverloaded:
+		return http.StatusServiceUnavailable
+	default:
+		return 422
+	}
+}
@@ -0,0 +1,73 @@
+package network
+
+import (
+	"strings"
+	"sync"
+)
+
+const memoryScheme = "mem://"
+
+// MemoryTransport delivers messages between services running
+// in the same process, without using real sockets
+type MemoryTransport struct {
+	mutex    sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{
+		handlers: make(map[string]Handler),
+	}
+}
+
+// MemoryAddress generates the in-memory address of a service
+func MemoryAddress(name string) string {
+	return memoryScheme + name
+}
+
+// IsMemoryAddress reports whether the address belongs to an in-memory service
+func IsMemoryAddress(address string) bool {
+	return strings.HasPrefix(address, memoryScheme)
+}
+
+func (t *MemoryTransport) Listen(address string, h Handler) error {
+	t.mutex.Lock()
+	t.handlers[address] = h
+	t.mutex.Unlock()
+	return nil
+}
+
+func (t *MemoryTransport) Close(address string) {
+	t.mutex.Lock()
+	delete(t.handlers, address)
+	t.mutex.Unlock()
+}
+
+func (t *MemoryTransport) SendMessage(address string, message Message, service string) error {
+	h, err := t.handler(address)
+	if err != nil {
+		return err
+	}
+
+	return h.HandleMessage(message, service)
+}
+
+func (t *MemoryTransport) SendResponse(address string, message Message) error {
+	h, err := t.handler(address)
+	if err != nil {
+		return err
+	}
+
+	return h.HandleResponse(message)
+}
+
+func (t *MemoryTransport) handler(address string) (Handler, error) {
+	t.mutex.RLock()
+	h, ok := t.handlers[address]
+	t.mutex.RUnlock()
+	if !ok {
+		return nil, ErrUnknownDestination
+	}
+
+	return h, nil
+}
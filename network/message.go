@@ -1,7 +1,6 @@
 package network
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"io"
@@ -14,43 +13,43 @@ type Message struct {
 	Sender string `json:"sender"`
 	Body   string `json:"body"`
 	Args   string `json:"args"`
+	// Reference of the request in the history of the sender,
+	// sent back with the response
+	Ref string `json:"ref,omitempty"`
+	// Time (milliseconds since the Unix epoch) after which
+	// the sender does not wait for the response anymore
+	Deadline int64  `json:"deadline,omitempty"`
+	Trace    string `json:"trace,omitempty"`
+	Span     string `json:"span,omitempty"`
 }
 
-var ErrNoSuchParam = errors.New("Parameter not found")
-
-func doRequest(method string, path string, body []byte) {
-	b := bytes.NewBuffer(body)
-	req, err := http.NewRequest(method, path, b)
-
-	if err != nil {
-		panic(err)
-	}
-
-	req.Header.Add("Content-type", "application/json")
-
-	client := &http.Client{}
-	client.Do(req)
+// Handler processes the messages received by a service
+type Handler interface {
+	HandleMessage(message Message, service string) error
+	HandleResponse(message Message) error
 }
 
-func Send(address string, message string, args string, from string, isResponse bool) {
-	path := address + "/message"
-	if isResponse {
-		path = address + "/response"
-	}
-	m := Message{
-		Sender: from,
-		Body:   message,
-		Args:   args,
-	}
-
-	data, err := json.Marshal(m)
-	if err != nil {
-		panic(err)
-	}
+// Transport delivers messages between services
+type Transport interface {
+	Listen(address string, h Handler) error
+	Close(address string)
+	SendMessage(address string, message Message, service string) error
+	SendResponse(address string, message Message) error
+}
 
-	doRequest("POST", path, data)
+// Router is implemented by the transports that can expose
+// additional endpoints of a service (e.g. to change it at runtime)
+type Router interface {
+	Handle(address string, pattern string, handler http.Handler) error
 }
 
+var (
+	ErrNoSuchParam        = errors.New("Parameter not found")
+	ErrUnprocessable      = errors.New("Cannot process message")
+	ErrUnknownDestination = errors.New("Unknown destination")
+	ErrOverloaded         = errors.New("Service overloaded")
+)
+
 func ReadMessage(r *http.Request) (Message, error) {
 	var err error
 	var message Message
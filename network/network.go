@@ -5,8 +5,6 @@ import (
 	"strconv"
 )
 
-var myAddress = ""
-
 // Ask the kernel for a free open port that is ready to use
 func GetPort() int {
 	addr, err := net.ResolveTCPAddr("tcp", "localhost:0")
@@ -50,15 +48,5 @@ func GenerateAddress(ip string, port string) string {
 		myPort = ":" + strconv.Itoa(p)
 	}
 
-	myAddress = "http://" + myIp + myPort
-
-	return myAddress
-}
-
-func GetMyAddress() string {
-	if myAddress != "" {
-		return myAddress
-	}
-
-	return GenerateAddress("", "")
+	return "http://" + myIp + myPort
 }
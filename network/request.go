@@ -3,10 +3,36 @@ package network
 import "time"
 
 type Request struct {
-	ID         string
-	From       string
-	To         string
-	Counter    int
-	Start      time.Time
-	ExecTimeMs float64
+	ID string
+	// Key identifies the request in the service, even if the
+	// same request arrives several times (e.g. from different senders)
+	Key         string
+	From        string
+	Ref         string
+	To          string
+	Counter     int
+	Start       time.Time
+	ExecTimeMs  float64
+	QueueTimeMs float64
+	QueueLength int
+	IOBytes     int64
+	IOTimeMs    float64
+	TraceID     string
+	SpanID      string
+	ParentSpan  string
+	Dispatched  time.Time
+	// Deadline is the time the sender stops waiting for the response,
+	// Expires the time the service stops waiting for its destinations
+	Deadline time.Time
+	Expires  time.Time
+	// Destination services that did not respond yet
+	Waiting []string
+	// Fan-out mode of the request: Counter is the number of responses
+	// (successful ones, with a quorum) still needed to complete it,
+	// Spare the failures it tolerates, Chain the services still to
+	// call in sequence and Failure the first failure received.
+	FanOut  string
+	Spare   int
+	Chain   []string
+	Failure string
 }
@@ -0,0 +1,121 @@
+package simulation
+
+import (
+	"log"
+	"math/rand"
+	"strconv"
+	"sync"
+
+	"github.com/elleFlorio/mu-sim/network"
+)
+
+const gatewayName = "gateway"
+
+// Gateway exposes the entry service of a simulation through HTTP,
+// so the in-memory graph can receive requests from the outside
+// exactly as a graph of real services does.
+type Gateway struct {
+	sim        *Simulation
+	entry      string
+	address    string
+	http       *network.HTTPTransport
+	memAddress string
+	counter    int
+	mutex      sync.Mutex
+	pending    map[string]string
+}
+
+type ingress struct{ g *Gateway }
+type egress struct{ g *Gateway }
+
+func NewGateway(sim *Simulation, entry string, address string) *Gateway {
+	return &Gateway{
+		sim:        sim,
+		entry:      entry,
+		address:    address,
+		http:       network.NewHTTPTransport(),
+		memAddress: network.MemoryAddress(gatewayName),
+		counter:    1,
+		pending:    make(map[string]string),
+	}
+}
+
+func (g *Gateway) Start() error {
+	if err := g.sim.transport.Listen(g.memAddress, egress{g}); err != nil {
+		return err
+	}
+	if err := g.http.Listen(g.address, ingress{g}); err != nil {
+		g.sim.transport.Close(g.memAddress)
+		return err
+	}
+	log.Printf("Gateway to %s listening on %s\n", g.entry, g.address)
+
+	return nil
+}
+
+func (g *Gateway) Stop() {
+	g.http.Close(g.address)
+	g.sim.transport.Close(g.memAddress)
+}
+
+// Requests from the outside are forwarded to a random instance of the
+// entry service, remembering who should receive the response
+func (in ingress) HandleMessage(message network.Message, service string) error {
+	g := in.g
+	instances, err := g.sim.directory.GetAvailableInstances(g.entry)
+	if err != nil {
+		return err
+	}
+
+	g.mutex.Lock()
+	if message.Args == "" {
+		message.Args = gatewayName + "-" + strconv.Itoa(g.counter)
+		g.counter++
+	}
+	g.pending[message.Args] = message.Sender
+	g.mutex.Unlock()
+
+	message.Sender = g.memAddress
+	err = g.sim.transport.SendMessage(instances[rand.Intn(len(instances))].Address, message, service)
+	if err != nil {
+		g.mutex.Lock()
+		delete(g.pending, message.Args)
+		g.mutex.Unlock()
+	}
+
+	return err
+}
+
+func (in ingress) HandleResponse(message network.Message) error {
+	return network.ErrUnprocessable
+}
+
+func (out egress) HandleMessage(message network.Message, service string) error {
+	return network.ErrUnprocessable
+}
+
+// Responses of the entry service are sent back to the original sender
+func (out egress) HandleResponse(message network.Message) error {
+	g := out.g
+	g.mutex.Lock()
+	sender, ok := g.pending[message.Args]
+	delete(g.pending, message.Args)
+	g.mutex.Unlock()
+
+	if !ok {
+		return network.ErrUnprocessable
+	}
+	log.Printf("Request %s completed: %s\n", message.Args, message.Body)
+	if sender == "" {
+		return nil
+	}
+
+	message.Sender = g.address
+	go func() {
+		if err := g.http.SendResponse(sender, message); err != nil {
+			log.Printf("Cannot forward response to %s: %s\n", sender, err.Error())
+		}
+	}()
+
+	return nil
+}
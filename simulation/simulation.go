@@ -0,0 +1,135 @@
+package simulation
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/elleFlorio/mu-sim/app"
+	"github.com/elleFlorio/mu-sim/discovery"
+	"github.com/elleFlorio/mu-sim/metric"
+	"github.com/elleFlorio/mu-sim/network"
+	"github.com/elleFlorio/mu-sim/topology"
+	"github.com/elleFlorio/mu-sim/tracing"
+)
+
+// Options controls how the services of the simulation are run
+type Options struct {
+	Influx  metric.InfluxConfig
+	Tracing tracing.Config
+	Quiet   bool
+}
+
+// Simulation runs a whole graph of services in a single process.
+// The services communicate through an in-memory transport and find
+// each other through an in-memory registry.
+type Simulation struct {
+	transport *network.MemoryTransport
+	directory *discovery.MemoryDirectory
+	services  []*app.Service
+	exporter  tracing.Exporter
+	tracers   []*tracing.Tracer
+}
+
+func New(topo topology.Topology, opts Options) (*Simulation, error) {
+	sim := &Simulation{
+		transport: network.NewMemoryTransport(),
+		directory: discovery.NewMemoryDirectory(),
+	}
+
+	exporter, err := tracing.NewExporter(opts.Tracing)
+	switch err {
+	case nil:
+		// Spans of every service end up in the same file or collector
+		sim.exporter = exporter
+	case tracing.ErrNotConfigured:
+	default:
+		return nil, err
+	}
+
+	for _, s := range topo.Services {
+		cfg := s.Config()
+		for i := 0; i < s.Replicas; i++ {
+			id := s.Name + "/" + strconv.Itoa(i)
+			rt := app.Runtime{
+				Address:   network.MemoryAddress(id),
+				Transport: sim.transport,
+				Registry:  sim.directory.NewRegistry(),
+				Metrics:   newRecorder(cfg, network.MemoryAddress(id), opts.Influx),
+			}
+			if sim.exporter != nil {
+				rt.Tracer = tracing.NewTracer(s.Name, sim.exporter)
+				sim.tracers = append(sim.tracers, rt.Tracer)
+			}
+			if !opts.Quiet {
+				rt.Logger = log.New(os.Stderr, "["+id+"] ", log.LstdFlags)
+			}
+			service, err := app.NewService(cfg, rt)
+			if err != nil {
+				return nil, err
+			}
+			sim.services = append(sim.services, service)
+		}
+	}
+
+	return sim, nil
+}
+
+func newRecorder(cfg app.Config, address string, influx metric.InfluxConfig) *metric.Recorder {
+	if influx.Address == "" {
+		return nil
+	}
+
+	recorder, err := metric.New(cfg.Name, cfg.Workload, address, influx)
+	if err != nil {
+		log.Printf("Error: %s; failed to initialize metric service for %s", err.Error(), address)
+		return nil
+	}
+
+	return recorder
+}
+
+// Start starts every service of the simulation
+func (sim *Simulation) Start() error {
+	for i, s := range sim.services {
+		if err := s.Start(); err != nil {
+			for _, started := range sim.services[:i] {
+				started.Stop()
+			}
+			return err
+		}
+	}
+	log.Printf("Started %d services\n", len(sim.services))
+
+	return nil
+}
+
+// Stop shuts down every service, waiting for the pending requests
+func (sim *Simulation) Stop() {
+	var wg sync.WaitGroup
+	for _, s := range sim.services {
+		wg.Add(1)
+		go func(s *app.Service) {
+			defer wg.Done()
+			s.Stop()
+		}(s)
+	}
+	wg.Wait()
+
+	for _, t := range sim.tracers {
+		t.Stop()
+	}
+	if sim.exporter != nil {
+		sim.exporter.Close()
+	}
+	log.Println("Simulation stopped")
+}
+
+func (sim *Simulation) Transport() *network.MemoryTransport {
+	return sim.transport
+}
+
+func (sim *Simulation) Directory() *discovery.MemoryDirectory {
+	return sim.directory
+}
@@ -0,0 +1,200 @@
+package topology
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/elleFlorio/mu-sim/Godeps/_workspace/src/gopkg.in/yaml.v2"
+
+	"github.com/elleFlorio/mu-sim/app"
+)
+
+// Topology describes a whole graph of MuSim services
+type Topology struct {
+	Services []Service `yaml:"services"`
+}
+
+// Service describes a node of the graph and how many replicas of it
+// should be started
+type Service struct {
+	Name             string        `yaml:"name"`
+	Workload         string        `yaml:"workload"`
+	Destinations     []string      `yaml:"destinations"`
+	FanOut           string        `yaml:"fanout"`
+	Quorum           int           `yaml:"quorum"`
+	Weights          []float64     `yaml:"weights"`
+	Balancers        []string      `yaml:"balancers"`
+	Zone             string        `yaml:"zone"`
+	Version          string        `yaml:"version"`
+	Weight           float64       `yaml:"weight"`
+	ZoneAffinity     bool          `yaml:"zone_affinity"`
+	Replicas         int           `yaml:"replicas"`
+	Port             int           `yaml:"port"`
+	Blocking         float64       `yaml:"blocking"`
+	Workers          int           `yaml:"workers"`
+	QueueLength      int           `yaml:"queue_length"`
+	Admission        string        `yaml:"admission"`
+	CoDelTarget      time.Duration `yaml:"codel_target"`
+	CoDelInterval    time.Duration `yaml:"codel_interval"`
+	Timeout          time.Duration `yaml:"timeout"`
+	Deadline         time.Duration `yaml:"deadline"`
+	Retries          int           `yaml:"retries"`
+	RetryBackoff     time.Duration `yaml:"retry_backoff"`
+	RetryMaxBackoff  time.Duration `yaml:"retry_max_backoff"`
+	BreakerThreshold int           `yaml:"breaker_threshold"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown"`
+	FaultErrorRate   float64       `yaml:"fault_error_rate"`
+	FaultLatency     time.Duration `yaml:"fault_latency"`
+	FaultDropRate    float64       `yaml:"fault_drop_rate"`
+	FaultCrashAfter  int           `yaml:"fault_crash_after"`
+	Memory           string        `yaml:"memory"`
+	MemoryLeak       float64       `yaml:"memory_leak"`
+	Disk             string        `yaml:"disk"`
+	DiskDir          string        `yaml:"disk_dir"`
+	DiskBlock        string        `yaml:"disk_block"`
+	DiskSync         string        `yaml:"disk_sync"`
+	DiskPattern      string        `yaml:"disk_pattern"`
+}
+
+const defaultWorkload = "medium"
+
+var (
+	ErrNoServices  = errors.New("No services defined in topology")
+	ErrMissingName = errors.New("Service name is missing")
+)
+
+// Load reads and validates the topology file at path
+func Load(path string) (Topology, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Topology{}, err
+	}
+
+	return Parse(data)
+}
+
+// Parse decodes a topology from its YAML (or JSON) representation,
+// filling the default values of the missing fields
+func Parse(data []byte) (Topology, error) {
+	var topo Topology
+	if err := yaml.UnmarshalStrict(data, &topo); err != nil {
+		return Topology{}, err
+	}
+
+	if err := topo.validate(); err != nil {
+		return Topology{}, err
+	}
+
+	return topo, nil
+}
+
+func (t *Topology) validate() error {
+	if len(t.Services) == 0 {
+		return ErrNoServices
+	}
+
+	names := make(map[string]bool, len(t.Services))
+	for i := range t.Services {
+		s := &t.Services[i]
+		if s.Name == "" {
+			return ErrMissingName
+		}
+		if names[s.Name] {
+			return fmt.Errorf("Service %s is defined more than once", s.Name)
+		}
+		names[s.Name] = true
+
+		if s.Workload == "" {
+			s.Workload = defaultWorkload
+		}
+		if _, err := s.Config().Validate(); err != nil {
+			return fmt.Errorf("Service %s: %s", s.Name, err)
+		}
+		if s.Replicas == 0 {
+			s.Replicas = 1
+		}
+		if s.Replicas < 0 {
+			return fmt.Errorf("Invalid number of replicas for service %s: %d", s.Name, s.Replicas)
+		}
+		if s.Port < 0 || s.Port+s.Replicas-1 > 65535 {
+			return fmt.Errorf("Invalid port for service %s: %d", s.Name, s.Port)
+		}
+	}
+
+	for _, s := range t.Services {
+		// The destinations are already validated
+		edges, _ := app.ParseEdges(s.Destinations)
+		for _, edge := range edges {
+			if !names[edge.Service] {
+				return fmt.Errorf("Destination %s of service %s is not defined", edge.Service, s.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Service returns the service with the given name
+func (t Topology) Service(name string) (Service, bool) {
+	for _, s := range t.Services {
+		if s.Name == name {
+			return s, true
+		}
+	}
+
+	return Service{}, false
+}
+
+// Config returns the configuration of the service instances
+func (s Service) Config() app.Config {
+	return app.Config{
+		Name:             s.Name,
+		Workload:         s.Workload,
+		Destinations:     s.Destinations,
+		FanOut:           s.FanOut,
+		Quorum:           s.Quorum,
+		Weights:          s.Weights,
+		Balancers:        s.Balancers,
+		Zone:             s.Zone,
+		Version:          s.Version,
+		Weight:           s.Weight,
+		ZoneAffinity:     s.ZoneAffinity,
+		Blocking:         s.Blocking,
+		Workers:          s.Workers,
+		QueueLength:      s.QueueLength,
+		Admission:        s.Admission,
+		CoDelTarget:      s.CoDelTarget,
+		CoDelInterval:    s.CoDelInterval,
+		Timeout:          s.Timeout,
+		Deadline:         s.Deadline,
+		Retries:          s.Retries,
+		RetryBackoff:     s.RetryBackoff,
+		RetryMaxBackoff:  s.RetryMaxBackoff,
+		BreakerThreshold: s.BreakerThreshold,
+		BreakerCooldown:  s.BreakerCooldown,
+		FaultErrorRate:   s.FaultErrorRate,
+		FaultLatency:     s.FaultLatency,
+		FaultDropRate:    s.FaultDropRate,
+		FaultCrashAfter:  s.FaultCrashAfter,
+		Memory:           s.Memory,
+		MemoryLeak:       s.MemoryLeak,
+		Disk:             s.Disk,
+		DiskDir:          s.DiskDir,
+		DiskBlock:        s.DiskBlock,
+		DiskSync:         s.DiskSync,
+		DiskPattern:      s.DiskPattern,
+	}
+}
+
+// ReplicaPort returns the port assigned to the i-th replica of the service.
+// If no port is specified in the topology it returns 0, meaning that the
+// replica should find a free port by itself.
+func (s Service) ReplicaPort(i int) int {
+	if s.Port == 0 {
+		return 0
+	}
+
+	return s.Port + i
+}
@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+var ErrNotConfigured = errors.New("Tracing is not configured")
+
+// Exporter writes the spans to their destination
+type Exporter interface {
+	Export(spans []Span) error
+	Close() error
+}
+
+// Config selects where the spans are exported
+type Config struct {
+	File      string
+	Collector string
+}
+
+// FileExporter appends the spans to a file, one JSON span per line
+type FileExporter struct {
+	mutex sync.Mutex
+	file  *os.File
+	w     *bufio.Writer
+}
+
+// CollectorExporter POSTs the spans to a collector accepting
+// the Zipkin v2 JSON format (e.g. http://localhost:9411/api/v2/spans)
+type CollectorExporter struct {
+	url    string
+	client *http.Client
+}
+
+// NewExporter creates the exporter for the configured destination.
+// The file takes precedence over the collector.
+func NewExporter(c Config) (Exporter, error) {
+	if c.File != "" {
+		exporter, err := NewFileExporter(c.File)
+		if err != nil {
+			return nil, err
+		}
+		return exporter, nil
+	}
+	if c.Collector != "" {
+		return NewCollectorExporter(c.Collector), nil
+	}
+
+	return nil, ErrNotConfigured
+}
+
+func NewFileExporter(path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileExporter{file: file, w: bufio.NewWriter(file)}, nil
+}
+
+func (e *FileExporter) Export(spans []Span) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, span := range spans {
+		data, err := json.Marshal(span)
+		if err != nil {
+			return err
+		}
+		e.w.Write(data)
+		e.w.WriteByte('\n')
+	}
+
+	return e.w.Flush()
+}
+
+func (e *FileExporter) Close() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.w.Flush()
+	return e.file.Close()
+}
+
+func NewCollectorExporter(url string) *CollectorExporter {
+	return &CollectorExporter{url: url, client: &http.Client{}}
+}
+
+func (e *CollectorExporter) Export(spans []Span) error {
+	data, err := json.Marshal(spans)
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Collector responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (e *CollectorExporter) Close() error {
+	return nil
+}
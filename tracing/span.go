@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Span is a span in the Zipkin v2 format
+type Span struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Name          string            `json:"name"`
+	Kind          string            `json:"kind,omitempty"`
+	Timestamp     int64             `json:"timestamp"`
+	Duration      int64             `json:"duration"`
+	LocalEndpoint Endpoint          `json:"localEndpoint"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+type Endpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+const KindServer = "SERVER"
+
+// NewSpan creates a span of the trace that started at start and ended at end
+func NewSpan(traceID string, id string, parentID string, name string, start time.Time, end time.Time) Span {
+	return Span{
+		TraceID:   traceID,
+		ID:        id,
+		ParentID:  parentID,
+		Name:      name,
+		Timestamp: start.UnixNano() / int64(time.Microsecond),
+		Duration:  int64(end.Sub(start) / time.Microsecond),
+		Tags:      make(map[string]string),
+	}
+}
+
+// NewTraceID generates a random 128 bit trace ID
+func NewTraceID() string {
+	return randomID(16)
+}
+
+// NewSpanID generates a random 64 bit span ID
+func NewSpanID() string {
+	return randomID(8)
+}
+
+func randomID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	flushInterval = time.Duration(1) * time.Second
+	maxBuffered   = 100
+	// Batches waiting to be exported: if the exporter
+	// cannot keep up, the new batches are dropped
+	maxPending = 10
+)
+
+// Tracer collects the spans of a service and exports them in batches.
+// The batches are exported in background, so a slow exporter (e.g. a
+// collector far away) never delays the requests being traced.
+type Tracer struct {
+	endpoint   Endpoint
+	exporter   Exporter
+	mutex      sync.Mutex
+	buffer     []Span
+	ch_batches chan []Span
+	ch_stop    chan struct{}
+	ch_done    chan struct{}
+}
+
+func NewTracer(serviceName string, exporter Exporter) *Tracer {
+	t := &Tracer{
+		endpoint:   Endpoint{ServiceName: serviceName},
+		exporter:   exporter,
+		ch_batches: make(chan []Span, maxPending),
+		ch_stop:    make(chan struct{}),
+		ch_done:    make(chan struct{}),
+	}
+	go t.run()
+
+	return t
+}
+
+// Record adds a span of the service to the next batch
+func (t *Tracer) Record(span Span) {
+	span.LocalEndpoint = t.endpoint
+
+	t.mutex.Lock()
+	t.buffer = append(t.buffer, span)
+	full := len(t.buffer) >= maxBuffered
+	t.mutex.Unlock()
+
+	if full {
+		t.Flush()
+	}
+}
+
+// Flush hands the buffered spans to the exporter. The spans
+// are dropped if too many batches are waiting to be exported.
+func (t *Tracer) Flush() {
+	spans := t.take()
+	if len(spans) == 0 {
+		return
+	}
+
+	select {
+	case t.ch_batches <- spans:
+	default:
+		log.Printf("Exporter busy, %d spans dropped\n", len(spans))
+	}
+}
+
+// Stop exports the remaining spans and stops the tracer.
+// The exporter is not closed, as it may be shared among tracers.
+func (t *Tracer) Stop() {
+	close(t.ch_stop)
+	<-t.ch_done
+}
+
+func (t *Tracer) take() []Span {
+	t.mutex.Lock()
+	spans := t.buffer
+	t.buffer = nil
+	t.mutex.Unlock()
+	return spans
+}
+
+// run exports the batches, and the buffered spans every flush interval
+func (t *Tracer) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	defer close(t.ch_done)
+
+	for {
+		select {
+		case spans := <-t.ch_batches:
+			t.export(spans)
+		case <-ticker.C:
+			t.export(t.take())
+		case <-t.ch_stop:
+			for {
+				select {
+				case spans := <-t.ch_batches:
+					t.export(spans)
+				default:
+					t.export(t.take())
+					return
+				}
+			}
+		}
+	}
+}
+
+func (t *Tracer) export(spans []Span) {
+	if len(spans) == 0 {
+		return
+	}
+	if err := t.exporter.Export(spans); err != nil {
+		log.Printf("Cannot export %d spans: %s\n", len(spans), err.Error())
+	}
+}
@@ -0,0 +1,155 @@
+package tracing
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingExporter keeps the exported spans, exporting
+// them only when unblocked if it is blocked
+type blockingExporter struct {
+	mutex      sync.Mutex
+	spans      []Span
+	ch_blocked chan struct{}
+}
+
+func (e *blockingExporter) Export(spans []Span) error {
+	if e.ch_blocked != nil {
+		<-e.ch_blocked
+	}
+	e.mutex.Lock()
+	e.spans = append(e.spans, spans...)
+	e.mutex.Unlock()
+	return nil
+}
+
+func (e *blockingExporter) Close() error {
+	return nil
+}
+
+func (e *blockingExporter) exported() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return len(e.spans)
+}
+
+func TestTracerStop(t *testing.T) {
+	exporter := &blockingExporter{}
+	tracer := NewTracer("a", exporter)
+
+	for i := 0; i < 3; i++ {
+		tracer.Record(NewSpan(NewTraceID(), NewSpanID(), "", "request", time.Now(), time.Now()))
+	}
+	tracer.Stop()
+
+	if exporter.exported() != 3 {
+		t.Fatalf("%d spans exported at stop, want 3", exporter.exported())
+	}
+	for _, span := range exporter.spans {
+		if span.LocalEndpoint.ServiceName != "a" {
+			t.Errorf("local endpoint = %+v, want service a", span.LocalEndpoint)
+		}
+	}
+}
+
+func TestTracerFullBuffer(t *testing.T) {
+	exporter := &blockingExporter{}
+	tracer := NewTracer("a", exporter)
+	defer tracer.Stop()
+
+	for i := 0; i < maxBuffered; i++ {
+		tracer.Record(NewSpan(NewTraceID(), NewSpanID(), "", "request", time.Now(), time.Now()))
+	}
+
+	// A full buffer is exported without waiting for the flush interval
+	deadline := time.Now().Add(flushInterval / 2)
+	for exporter.exported() < maxBuffered {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d spans exported, want the full buffer", exporter.exported())
+		}
+		time.Sleep(time.Duration(5) * time.Millisecond)
+	}
+}
+
+func TestTracerSlowExporter(t *testing.T) {
+	exporter := &blockingExporter{ch_blocked: make(chan struct{})}
+	tracer := NewTracer("a", exporter)
+
+	// Recording never waits for the exporter
+	recorded := (maxPending + 5) * maxBuffered
+	ch_recorded := make(chan struct{})
+	go func() {
+		for i := 0; i < recorded; i++ {
+			tracer.Record(NewSpan(NewTraceID(), NewSpanID(), "", "request", time.Now(), time.Now()))
+		}
+		close(ch_recorded)
+	}()
+	select {
+	case <-ch_recorded:
+	case <-time.After(time.Duration(5) * time.Second):
+		t.Fatal("Record() blocked by the exporter")
+	}
+
+	close(exporter.ch_blocked)
+	tracer.Stop()
+	if exported := exporter.exported(); exported == 0 || exported >= recorded {
+		t.Errorf("%d of %d spans exported, want the spans in excess dropped", exported, recorded)
+	}
+}
+
+func TestSpanJSON(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	span := NewSpan("463ac35c9f6413ad48485a3953bb6124", "a2fb4a1d1a96d312", "", "request",
+		start, start.Add(time.Duration(1500)*time.Microsecond))
+	span.Kind = KindServer
+	span.LocalEndpoint = Endpoint{ServiceName: "a"}
+	span.Tags["response"] = "done"
+
+	data, err := json.Marshal(span)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// Zipkin v2: timestamps and durations in microseconds,
+	// no parent for the root span
+	want := map[string]interface{}{
+		"traceId":       "463ac35c9f6413ad48485a3953bb6124",
+		"id":            "a2fb4a1d1a96d312",
+		"name":          "request",
+		"kind":          "SERVER",
+		"timestamp":     float64(1500000000000000),
+		"duration":      float64(1500),
+		"localEndpoint": map[string]interface{}{"serviceName": "a"},
+		"tags":          map[string]interface{}{"response": "done"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("JSON of the span = %s, want %v", data, want)
+	}
+
+	child := NewSpan(span.TraceID, NewSpanID(), span.ID, "queue", start, start)
+	data, _ = json.Marshal(child)
+	got = nil
+	json.Unmarshal(data, &got)
+	if got["parentId"] != span.ID {
+		t.Errorf("parentId = %v, want %s", got["parentId"], span.ID)
+	}
+}
+
+func TestIDs(t *testing.T) {
+	if id := NewTraceID(); len(id) != 32 {
+		t.Errorf("NewTraceID() = %q, want 32 hex digits", id)
+	}
+	if id := NewSpanID(); len(id) != 16 {
+		t.Errorf("NewSpanID() = %q, want 16 hex digits", id)
+	}
+	if NewSpanID() == NewSpanID() {
+		t.Error("NewSpanID() returned the same ID twice")
+	}
+}
@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"time"
+)
+
+const (
+	SyncNone  = "none"
+	SyncEnd   = "end"
+	SyncBlock = "block"
+
+	PatternSequential = "sequential"
+	PatternRandom     = "random"
+)
+
+// DiskLoad describes the disk operations performed by every job:
+// Size bytes are written to a scratch file and then read back
+type DiskLoad struct {
+	Dir       string
+	Size      int64
+	BlockSize int64
+	// When the written data is flushed to disk (none, end, block)
+	Sync string
+	// How the blocks of the file are accessed (sequential, random)
+	Pattern string
+}
+
+var (
+	ErrInvalidSync    = errors.New("Invalid disk sync mode")
+	ErrInvalidPattern = errors.New("Invalid disk access pattern")
+	ErrInvalidBlock   = errors.New("Invalid disk block size")
+)
+
+func (d DiskLoad) Validate() error {
+	if d.Size <= 0 {
+		return nil
+	}
+	if d.BlockSize <= 0 {
+		return ErrInvalidBlock
+	}
+	switch d.Sync {
+	case SyncNone, SyncEnd, SyncBlock:
+	default:
+		return ErrInvalidSync
+	}
+	switch d.Pattern {
+	case PatternSequential, PatternRandom:
+	default:
+		return ErrInvalidPattern
+	}
+
+	return nil
+}
+
+// run writes and reads back the scratch file of the job, returning
+// the number of bytes transferred and the time spent doing it
+func (d DiskLoad) run() (int64, float64, error) {
+	if d.Size <= 0 {
+		return 0, 0, nil
+	}
+
+	start := time.Now()
+	f, err := ioutil.TempFile(d.Dir, "mu-sim-")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	blocks := d.Size / d.BlockSize
+	if d.Size%d.BlockSize != 0 {
+		blocks++
+	}
+	if err = f.Truncate(blocks * d.BlockSize); err != nil {
+		return 0, computeExecutionTime(start), err
+	}
+
+	// Read keeps its own state in the Rand, so the
+	// shared generator cannot be used concurrently
+	buf := make([]byte, d.BlockSize)
+	rand.New(rand.NewSource(gen.Int63())).Read(buf)
+
+	var transferred int64
+	for i := int64(0); i < blocks; i++ {
+		n, err := f.WriteAt(buf, d.offset(i, blocks))
+		transferred += int64(n)
+		if err != nil {
+			return transferred, computeExecutionTime(start), err
+		}
+		if d.Sync == SyncBlock {
+			if err = f.Sync(); err != nil {
+				return transferred, computeExecutionTime(start), err
+			}
+		}
+	}
+	if d.Sync == SyncEnd {
+		if err = f.Sync(); err != nil {
+			return transferred, computeExecutionTime(start), err
+		}
+	}
+
+	for i := int64(0); i < blocks; i++ {
+		n, err := f.ReadAt(buf, d.offset(i, blocks))
+		transferred += int64(n)
+		if err != nil {
+			return transferred, computeExecutionTime(start), err
+		}
+	}
+
+	return transferred, computeExecutionTime(start), nil
+}
+
+func (d DiskLoad) offset(i int64, blocks int64) int64 {
+	if d.Pattern == PatternRandom {
+		return gen.Int63n(blocks) * d.BlockSize
+	}
+	return i * d.BlockSize
+}
@@ -0,0 +1,143 @@
+package worker
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Distribution generates the service time of the jobs, in milliseconds
+type Distribution interface {
+	Sample(gen *rand.Rand) float64
+}
+
+type Constant struct {
+	Value float64
+}
+
+type Exponential struct {
+	Mean float64
+}
+
+type Uniform struct {
+	Min float64
+	Max float64
+}
+
+type Normal struct {
+	Mean   float64
+	StdDev float64
+}
+
+type LogNormal struct {
+	Mu    float64
+	Sigma float64
+}
+
+type Pareto struct {
+	Scale float64
+	Shape float64
+}
+
+type Weibull struct {
+	Scale float64
+	Shape float64
+}
+
+// Bimodal is a mixture of two normal distributions: the first one
+// is chosen with probability P
+type Bimodal struct {
+	First  Normal
+	Second Normal
+	P      float64
+}
+
+// Empirical samples from a histogram: a bin is chosen according to
+// its weight, then the value is uniformly distributed inside the bin
+type Empirical struct {
+	bounds     []float64
+	cumWeights []float64
+}
+
+// Bin is a bin of an empirical histogram. It covers the values from
+// the upper bound of the previous bin (or 0) to its UpperBound.
+type Bin struct {
+	UpperBound float64
+	Weight     float64
+}
+
+func (d Constant) Sample(gen *rand.Rand) float64 {
+	return d.Value
+}
+
+func (d Exponential) Sample(gen *rand.Rand) float64 {
+	return gen.ExpFloat64() * d.Mean
+}
+
+func (d Uniform) Sample(gen *rand.Rand) float64 {
+	return d.Min + gen.Float64()*(d.Max-d.Min)
+}
+
+// Negative values are truncated to 0, as a job cannot last less than nothing
+func (d Normal) Sample(gen *rand.Rand) float64 {
+	return math.Max(0, d.Mean+gen.NormFloat64()*d.StdDev)
+}
+
+func (d LogNormal) Sample(gen *rand.Rand) float64 {
+	return math.Exp(d.Mu + gen.NormFloat64()*d.Sigma)
+}
+
+func (d Pareto) Sample(gen *rand.Rand) float64 {
+	return d.Scale / math.Pow(1-gen.Float64(), 1/d.Shape)
+}
+
+func (d Weibull) Sample(gen *rand.Rand) float64 {
+	return d.Scale * math.Pow(-math.Log(1-gen.Float64()), 1/d.Shape)
+}
+
+func (d Bimodal) Sample(gen *rand.Rand) float64 {
+	if gen.Float64() < d.P {
+		return d.First.Sample(gen)
+	}
+	return d.Second.Sample(gen)
+}
+
+func NewEmpirical(bins []Bin) Empirical {
+	sorted := make([]Bin, len(bins))
+	copy(sorted, bins)
+	sort.Sort(byUpperBound(sorted))
+
+	d := Empirical{}
+	total := 0.0
+	for _, b := range sorted {
+		total += b.Weight
+		d.bounds = append(d.bounds, b.UpperBound)
+		d.cumWeights = append(d.cumWeights, total)
+	}
+
+	return d
+}
+
+func (d Empirical) Sample(gen *rand.Rand) float64 {
+	if len(d.bounds) == 0 {
+		return 0
+	}
+
+	w := gen.Float64() * d.cumWeights[len(d.cumWeights)-1]
+	i := sort.SearchFloat64s(d.cumWeights, w)
+	if i == len(d.cumWeights) {
+		i--
+	}
+
+	lower := 0.0
+	if i > 0 {
+		lower = d.bounds[i-1]
+	}
+	return lower + gen.Float64()*(d.bounds[i]-lower)
+}
+
+type byUpperBound []Bin
+
+func (b byUpperBound) Len() int           { return len(b) }
+func (b byUpperBound) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byUpperBound) Less(i, j int) bool { return b[i].UpperBound < b[j].UpperBound }
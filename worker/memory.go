@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const pageSize = 4096
+
+// MemoryLoad describes the heap used by every job
+type MemoryLoad struct {
+	// Bytes allocated and held for the whole job
+	Size int64
+	// Fraction of the allocated bytes that is never released,
+	// to simulate memory leaks
+	Leak float64
+}
+
+var (
+	leaked  [][]byte
+	mutex_l = &sync.Mutex{}
+)
+
+// allocate returns the memory held by the job: the part that will be
+// released and the part that will leak when the job is over
+func (m MemoryLoad) allocate() ([]byte, []byte) {
+	if m.Size <= 0 {
+		return nil, nil
+	}
+
+	leakSize := int64(float64(m.Size) * m.Leak)
+	held := touch(make([]byte, m.Size-leakSize))
+	leak := touch(make([]byte, leakSize))
+
+	return held, leak
+}
+
+func (m MemoryLoad) release(leak []byte) {
+	if len(leak) == 0 {
+		return
+	}
+
+	mutex_l.Lock()
+	leaked = append(leaked, leak)
+	mutex_l.Unlock()
+}
+
+// Write every page, so the memory is actually resident
+// and not only reserved
+func touch(buf []byte) []byte {
+	for i := 0; i < len(buf); i += pageSize {
+		buf[i] = 1
+	}
+	return buf
+}
+
+// ParseSize parses an amount of memory like "512", "64KB", "10MB" or "1GB"
+func ParseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		value  int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.value
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("Invalid size %s", size)
+	}
+
+	return int64(n * float64(multiplier)), nil
+}
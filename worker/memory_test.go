@@ -0,0 +1,50 @@
+package worker
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size string
+		want int64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"512B", 512},
+		{"64KB", 64 << 10},
+		{"64kb", 64 << 10},
+		{" 10 MB ", 10 << 20},
+		{"1.5MB", 3 << 19},
+		{"1GB", 1 << 30},
+	}
+
+	for _, test := range tests {
+		got, err := ParseSize(test.size)
+		if err != nil {
+			t.Errorf("ParseSize(%q): unexpected error %s", test.size, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", test.size, got, test.want)
+		}
+	}
+}
+
+func TestParseSizeInvalid(t *testing.T) {
+	for _, size := range []string{"", "MB", "abc", "-1KB", "10TB"} {
+		if got, err := ParseSize(size); err == nil {
+			t.Errorf("ParseSize(%q) = %d, want an error", size, got)
+		}
+	}
+}
+
+func TestMemoryLoadAllocate(t *testing.T) {
+	held, leak := MemoryLoad{Size: 1000, Leak: 0.25}.allocate()
+	if len(held) != 750 || len(leak) != 250 {
+		t.Errorf("allocate() = %d held and %d leaked bytes, want 750 and 250", len(held), len(leak))
+	}
+
+	held, leak = MemoryLoad{}.allocate()
+	if held != nil || leak != nil {
+		t.Errorf("allocate() of an empty load = %d held and %d leaked bytes, want none", len(held), len(leak))
+	}
+}
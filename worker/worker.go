@@ -3,6 +3,7 @@ package worker
 import (
 	"log"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/elleFlorio/mu-sim/network"
@@ -10,45 +11,80 @@ import (
 
 const (
 	c_MAXITER = 100
-	c_LOW     = 1000
-	c_MEDIUM  = 5000
-	c_HEAVY   = 10000
 )
 
 var (
-	source   rand.Source
-	gen      *rand.Rand
-	workload string
+	source rand.Source
+	gen    *rand.Rand
 )
 
+// rand.Rand is not safe for concurrent use, while
+// workers sample their service time concurrently
+type lockedSource struct {
+	mutex sync.Mutex
+	src   rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mutex.Lock()
+	n := s.src.Int63()
+	s.mutex.Unlock()
+	return n
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mutex.Lock()
+	s.src.Seed(seed)
+	s.mutex.Unlock()
+}
+
 func init() {
-	source = rand.NewSource(time.Now().UnixNano())
+	source = &lockedSource{src: rand.NewSource(time.Now().UnixNano())}
 	gen = rand.New(source)
 }
 
-func Work(workload string, req network.Request, ch_done chan network.Request) {
-	var load float64
-
-	switch workload {
-	case "none":
-		load = 0
-	case "low":
-		load = gen.ExpFloat64() * c_LOW
-	case "medium":
-		load = gen.ExpFloat64() * c_MEDIUM
-	case "heavy":
-		load = gen.ExpFloat64() * c_HEAVY
-	default:
-		log.Println("Undefined workload ", workload)
+// Job describes the resources consumed by every request
+type Job struct {
+	Workload Distribution
+	// Fraction of the service time spent blocked (e.g. waiting for
+	// a remote database) instead of computing
+	Blocking float64
+	Memory   MemoryLoad
+	Disk     DiskLoad
+}
+
+func Work(job Job, req network.Request, ch_done chan network.Request) {
+	start := time.Now()
+	load := time.Duration(job.Workload.Sample(gen) * float64(time.Millisecond))
+	blocked := time.Duration(float64(load) * job.Blocking)
+	held, leak := job.Memory.allocate()
+	defer job.Memory.release(leak)
+
+	var err error
+	req.IOBytes, req.IOTimeMs, err = job.Disk.run()
+	if err != nil {
+		log.Println("Disk operations failed: ", err)
+	}
+
+	compute(load - blocked)
+	time.Sleep(blocked)
+
+	// Keeps the memory referenced till the end of the job
+	touch(held)
+	req.ExecTimeMs = computeExecutionTime(start)
+	ch_done <- req
+}
+
+// compute keeps the CPU busy for the given time
+func compute(d time.Duration) {
+	if d <= 0 {
 		return
 	}
 
-	timer := time.NewTimer(time.Millisecond * time.Duration(load))
+	timer := time.NewTimer(d)
 	for {
 		select {
 		case <-timer.C:
-			req.ExecTimeMs = computeExecutionTime(req.Start)
-			ch_done <- req
 			return
 		default:
 			cpuTest()
@@ -0,0 +1,184 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	c_LOW    = 1000
+	c_MEDIUM = 5000
+	c_HEAVY  = 10000
+)
+
+var ErrInvalidWorkload = errors.New("Invalid workload")
+
+type params map[string]float64
+
+// ParseWorkload creates the distribution of the service time described by
+// the workload. The workload can be one of the presets (none, low, medium,
+// heavy) or a distribution with its parameters in milliseconds, in the form
+// name:param=value,param=value (e.g. "lognormal:mu=3,sigma=0.5").
+func ParseWorkload(workload string) (Distribution, error) {
+	switch workload {
+	case "none":
+		return Constant{0}, nil
+	case "low":
+		return Exponential{c_LOW}, nil
+	case "medium":
+		return Exponential{c_MEDIUM}, nil
+	case "heavy":
+		return Exponential{c_HEAVY}, nil
+	}
+
+	name, args := workload, ""
+	if i := strings.Index(workload, ":"); i >= 0 {
+		name, args = workload[:i], workload[i+1:]
+	}
+
+	p, err := parseParams(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %s", ErrInvalidWorkload, workload, err)
+	}
+
+	d, err := newDistribution(name, p)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %s", ErrInvalidWorkload, workload, err)
+	}
+
+	return d, nil
+}
+
+func newDistribution(name string, p params) (Distribution, error) {
+	switch name {
+	case "constant":
+		if err := p.check("value"); err != nil {
+			return nil, err
+		}
+		return Constant{p["value"]}, nil
+	case "exponential", "exp":
+		if err := p.check("mean"); err != nil {
+			return nil, err
+		}
+		return Exponential{p["mean"]}, nil
+	case "uniform":
+		if err := p.check("min", "max"); err != nil {
+			return nil, err
+		}
+		if p["max"] < p["min"] {
+			return nil, errors.New("max must be greater than min")
+		}
+		return Uniform{p["min"], p["max"]}, nil
+	case "normal":
+		if err := p.check("mean", "stddev"); err != nil {
+			return nil, err
+		}
+		return Normal{p["mean"], p["stddev"]}, nil
+	case "lognormal":
+		if err := p.check("mu", "sigma"); err != nil {
+			return nil, err
+		}
+		return LogNormal{p["mu"], p["sigma"]}, nil
+	case "pareto":
+		if err := p.check("scale", "shape"); err != nil {
+			return nil, err
+		}
+		if p["shape"] <= 0 {
+			return nil, errors.New("shape must be positive")
+		}
+		return Pareto{p["scale"], p["shape"]}, nil
+	case "weibull":
+		if err := p.check("scale", "shape"); err != nil {
+			return nil, err
+		}
+		if p["shape"] <= 0 {
+			return nil, errors.New("shape must be positive")
+		}
+		return Weibull{p["scale"], p["shape"]}, nil
+	case "bimodal":
+		if err := p.check("mean1", "stddev1", "mean2", "stddev2", "p"); err != nil {
+			return nil, err
+		}
+		if p["p"] > 1 {
+			return nil, errors.New("p must be a probability")
+		}
+		return Bimodal{
+			First:  Normal{p["mean1"], p["stddev1"]},
+			Second: Normal{p["mean2"], p["stddev2"]},
+			P:      p["p"],
+		}, nil
+	case "empirical":
+		// Every parameter is a bin of the histogram: upperbound=weight
+		if len(p) == 0 {
+			return nil, errors.New("empirical distribution needs at least a bin")
+		}
+		bins := make([]Bin, 0, len(p))
+		total := 0.0
+		for bound, weight := range p {
+			upper, err := strconv.ParseFloat(bound, 64)
+			if err != nil || upper < 0 {
+				return nil, fmt.Errorf("invalid bin %s", bound)
+			}
+			bins = append(bins, Bin{upper, weight})
+			total += weight
+		}
+		if total == 0 {
+			return nil, errors.New("the weights of the bins cannot be all zero")
+		}
+		return NewEmpirical(bins), nil
+	default:
+		return nil, fmt.Errorf("unknown distribution %s", name)
+	}
+}
+
+func parseParams(args string) (params, error) {
+	p := params{}
+	if args == "" {
+		return p, nil
+	}
+
+	for _, arg := range strings.Split(args, ",") {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid parameter %s", arg)
+		}
+		name := strings.TrimSpace(kv[0])
+		value, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of parameter %s", name)
+		}
+		if value < 0 && name != "mu" {
+			return nil, fmt.Errorf("parameter %s cannot be negative", name)
+		}
+		p[name] = value
+	}
+
+	return p, nil
+}
+
+// check ensures that all the required parameters,
+// and only them, have been provided
+func (p params) check(required ...string) error {
+	for _, name := range required {
+		if _, ok := p[name]; !ok {
+			return fmt.Errorf("missing parameter %s", name)
+		}
+	}
+	if len(p) > len(required) {
+		for name := range p {
+			found := false
+			for _, r := range required {
+				if r == name {
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("unknown parameter %s", name)
+			}
+		}
+	}
+
+	return nil
+}
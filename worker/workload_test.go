@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestParseWorkload(t *testing.T) {
+	tests := []struct {
+		workload string
+		want     Distribution
+	}{
+		{"none", Constant{0}},
+		{"low", Exponential{c_LOW}},
+		{"medium", Exponential{c_MEDIUM}},
+		{"heavy", Exponential{c_HEAVY}},
+		{"constant:value=20", Constant{20}},
+		{"exponential:mean=30", Exponential{30}},
+		{"exp:mean=30", Exponential{30}},
+		{"uniform:min=10,max=50", Uniform{10, 50}},
+		{"normal:mean=100,stddev=10", Normal{100, 10}},
+		{"lognormal:mu=3,sigma=0.5", LogNormal{3, 0.5}},
+		{"lognormal:mu=-1,sigma=0.5", LogNormal{-1, 0.5}},
+		{"lognormal: mu=-1, sigma=0.5", LogNormal{-1, 0.5}},
+		{"pareto:scale=10,shape=2", Pareto{10, 2}},
+		{"weibull:scale=10,shape=1.5", Weibull{10, 1.5}},
+		{"bimodal:mean1=10,stddev1=1,mean2=100,stddev2=10,p=0.9", Bimodal{
+			First:  Normal{10, 1},
+			Second: Normal{100, 10},
+			P:      0.9,
+		}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseWorkload(test.workload)
+		if err != nil {
+			t.Errorf("ParseWorkload(%q): unexpected error %s", test.workload, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseWorkload(%q) = %#v, want %#v", test.workload, got, test.want)
+		}
+	}
+}
+
+func TestParseWorkloadInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"unknown",
+		"gamma:k=2",
+		"constant",
+		"constant:value",
+		"constant:value=abc",
+		"constant:value=-1",
+		"constant:value=1,mean=2",
+		"exponential:value=1",
+		"uniform:min=50,max=10",
+		"normal:mean=-10,stddev=1",
+		"lognormal:mu=3,sigma=-0.5",
+		"pareto:scale=10,shape=0",
+		"weibull:scale=10,shape=0",
+		"bimodal:mean1=10,stddev1=1,mean2=100,stddev2=10,p=2",
+		"empirical",
+		"empirical:abc=1",
+		"empirical:10=0,20=0",
+	}
+
+	for _, workload := range tests {
+		if d, err := ParseWorkload(workload); err == nil {
+			t.Errorf("ParseWorkload(%q) = %#v, want an error", workload, d)
+		}
+	}
+}
+
+func TestEmpirical(t *testing.T) {
+	d, err := ParseWorkload("empirical:10=0,20=1,30=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		// Only the bin between 10 and 20 has a weight
+		if x := d.Sample(gen); x < 10 || x > 20 {
+			t.Fatalf("Sample() = %f, want a value between 10 and 20", x)
+		}
+	}
+}
+
+func TestDistributionsSample(t *testing.T) {
+	tests := []struct {
+		d        Distribution
+		min, max float64
+	}{
+		{Constant{5}, 5, 5},
+		{Uniform{10, 20}, 10, 20},
+		{Pareto{10, 2}, 10, 1e12},
+		{Exponential{10}, 0, 1e12},
+	}
+
+	gen := rand.New(rand.NewSource(1))
+	for _, test := range tests {
+		for i := 0; i < 1000; i++ {
+			if x := test.d.Sample(gen); x < test.min || x > test.max {
+				t.Fatalf("%#v.Sample() = %f, want a value between %f and %f", test.d, x, test.min, test.max)
+			}
+		}
+	}
+}